@@ -0,0 +1,113 @@
+package cjungo
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	echoSwagger "github.com/swaggo/echo-swagger"
+	"go.uber.org/dig"
+)
+
+type AdminServerConf struct {
+	Host    *string  `env:"CJUNGO_ADMIN_HOST" default:"127.0.0.1"`
+	Port    *uint16  `env:"CJUNGO_ADMIN_PORT" min:"1"`            // 为空时不启用管理端口
+	Listens []string `env:"CJUNGO_ADMIN_LISTENS"`                 // 格式同 HttpServerConf.Listens ，为空时监听 Host:Port
+	IsPprof bool     `env:"CJUNGO_ADMIN_IS_PPROF" default:"true"` // 挂载 /debug/pprof
+}
+
+// 管理端口的路由，诊断类接口（swagger 、健康检查、指标等）挂在这里，不暴露在主服务器上。
+type AdminRouter struct {
+	HttpRouter
+	conf *AdminServerConf
+}
+
+type NewAdminRouterDi struct {
+	dig.In
+	Conf     *AdminServerConf `optional:"true"`
+	HttpConf *HttpServerConf  `optional:"true"`
+	Metrics  *Metrics         `optional:"true"`
+	Logger   *zerolog.Logger
+}
+
+// 没有配置管理端口时返回 nil 。
+func NewAdminRouter(di NewAdminRouterDi) *AdminRouter {
+	if di.Conf == nil || (di.Conf.Port == nil && len(di.Conf.Listens) == 0) {
+		di.Logger.Info().Str("action", "没有启用管理端口").Msg("[ADMIN]")
+		return nil
+	}
+	admin := &AdminRouter{
+		HttpRouter: NewRouter(NewRouterDi{Logger: di.Logger}),
+		conf:       di.Conf,
+	}
+
+	if di.HttpConf != nil && di.HttpConf.IsSwag {
+		admin.GET("/swagger/*", wrapEchoHandler(echoSwagger.WrapHandler))
+		di.Logger.Info().Strs("address", admin.addresses()).Str("path", "/swagger/").Msg("[SWAG]")
+	}
+	di.Metrics.mount(admin)
+	if di.Conf.IsPprof {
+		admin.Any("/debug/pprof/*", func(ctx HttpContext) error {
+			switch name := ctx.Param("*"); name {
+			case "":
+				pprof.Index(ctx.Response(), ctx.Request())
+			case "cmdline":
+				pprof.Cmdline(ctx.Response(), ctx.Request())
+			case "profile":
+				pprof.Profile(ctx.Response(), ctx.Request())
+			case "symbol":
+				pprof.Symbol(ctx.Response(), ctx.Request())
+			case "trace":
+				pprof.Trace(ctx.Response(), ctx.Request())
+			default:
+				pprof.Handler(name).ServeHTTP(ctx.Response(), ctx.Request())
+			}
+			return nil
+		})
+	}
+	return admin
+}
+
+func LoadAdminServerConfFromEnv(logger *zerolog.Logger) (*AdminServerConf, error) {
+	logger.Info().Str("action", "通过环境变量配置管理端口").Msg("[ADMIN]")
+	conf := &AdminServerConf{}
+	if err := LoadConf(conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func (admin *AdminRouter) addresses() []string {
+	if len(admin.conf.Listens) > 0 {
+		return admin.conf.Listens
+	}
+	host := GetOrDefault(admin.conf.Host, "127.0.0.1")
+	return []string{net.JoinHostPort(host, strconv.Itoa(int(*admin.conf.Port)))}
+}
+
+// 管理端口的服务器，由 Application 随主服务器启动。
+func (admin *AdminRouter) NewServer(logger *zerolog.Logger) *HttpSideServer {
+	server := &http.Server{
+		Handler:           admin.GetHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return NewHttpSideServer("AdminServer", logger, server, admin.addresses()...)
+}
+
+// 诊断类接口挂载的路由：有管理端口时用管理端口，否则用主路由。
+func DiagnosticsRouter(router HttpRouter, admin *AdminRouter) HttpRouter {
+	if admin != nil {
+		return admin
+	}
+	return router
+}
+
+func wrapEchoHandler(h echo.HandlerFunc) HttpHandlerFunc {
+	return func(ctx HttpContext) error {
+		return h(ctx)
+	}
+}
@@ -0,0 +1,118 @@
+package cjungo
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestNewAdminRouterDisabled(t *testing.T) {
+	logger := zerolog.Nop()
+	port := uint16(9000)
+	for _, conf := range []*AdminServerConf{nil, {}} {
+		if admin := NewAdminRouter(NewAdminRouterDi{Conf: conf, Logger: &logger}); admin != nil {
+			t.Errorf("%+v 时不应启用管理端口", conf)
+		}
+	}
+	if NewAdminRouter(NewAdminRouterDi{Conf: &AdminServerConf{Port: &port}, Logger: &logger}) == nil {
+		t.Error("配置端口时应启用管理端口")
+	}
+}
+
+func TestAdminRouterDiagnostics(t *testing.T) {
+	logger := zerolog.Nop()
+	metrics := newTestMetrics(t)
+	conf := &HttpServerConf{IsSwag: true}
+	admin := NewAdminRouter(NewAdminRouterDi{
+		Conf:     &AdminServerConf{Listens: []string{"127.0.0.1:0"}, IsPprof: true},
+		HttpConf: conf,
+		Metrics:  metrics,
+		Logger:   &logger,
+	})
+	router := NewRouter(NewRouterDi{Conf: conf, Admin: admin, Metrics: metrics, Logger: &logger})
+	get := func(router HttpRouter, path string) int {
+		rec := httptest.NewRecorder()
+		router.GetHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	// swagger 、指标、pprof 只挂在管理端口。
+	for _, path := range []string{"/swagger/index.html", "/metrics", "/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/goroutine"} {
+		if code := get(admin, path); code != http.StatusOK {
+			t.Errorf("管理端口 %s 返回 %d", path, code)
+		}
+		if code := get(router, path); code != http.StatusNotFound {
+			t.Errorf("主路由 %s 返回 %d", path, code)
+		}
+	}
+
+	// 关闭 pprof 。
+	admin = NewAdminRouter(NewAdminRouterDi{Conf: &AdminServerConf{Listens: []string{"127.0.0.1:0"}}, Logger: &logger})
+	if code := get(admin, "/debug/pprof/"); code != http.StatusNotFound {
+		t.Errorf("关闭 pprof 时返回 %d", code)
+	}
+}
+
+func TestApplicationRunAdminServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	app, err := NewApplication(func(container DiContainer) error {
+		return container.Provides(
+			func() *LoggerConf { return &LoggerConf{} },
+			func() *HttpServerConf { return &HttpServerConf{Listens: []string{"127.0.0.1:0"}} },
+			func() *AdminServerConf { return &AdminServerConf{Listens: []string{"unix://" + path}} },
+			func(router HttpRouter) http.Handler { return router.GetHandler() },
+		)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DisableKeepAlives: true,
+		DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	get := func() (int, error) {
+		resp, err := client.Get("http://admin/livez")
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.RunContext(ctx) }()
+
+	// 管理端口随应用启动，健康检查挂在管理端口。
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		code, err := get()
+		if err == nil {
+			if code != http.StatusOK {
+				t.Fatalf("livez 返回 %d", code)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("管理端口没有启动: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 应用停止时关闭管理端口。
+	cancel()
+	if err := waitRun(t, done, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(); err == nil {
+		t.Fatal("应用停止后管理端口应关闭")
+	}
+}
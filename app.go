@@ -1,15 +1,26 @@
 package cjungo
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/rs/zerolog"
 	"go.uber.org/dig"
 )
 
+type ApplicationStopHandle func(ctx context.Context, container DiContainer) error
+
 type Application struct {
 	container DiContainer
 	BeforeRun func(DiContainer) error
+	onStops   []ApplicationStopHandle
 }
 
 type ApplicationInitHandle func(container DiContainer) error
@@ -20,9 +31,13 @@ func NewApplication(handle ApplicationInitHandle) (*Application, error) {
 	}
 
 	if err := container.Provides(
-		NewLogger,     // 日志
-		NewRouter,     // 路由
-		NewHttpServer, // 服务器
+		NewLogger,         // 日志
+		NewMetrics,        // 指标，没有配置 MetricsConf 时为 nil
+		NewTracing,        // 链路追踪，没有配置导出时为 nil
+		NewAdminRouter,    // 管理端口路由，没有配置 AdminServerConf 时为 nil
+		NewRouter,         // 路由
+		NewTlsHttpServer,  // 服务器，配置了证书时启用 HTTPS
+		NewHealthRegistry, // 健康检查
 	); err != nil {
 		return nil, err
 	}
@@ -35,34 +50,177 @@ func NewApplication(handle ApplicationInitHandle) (*Application, error) {
 	return &Application{
 		container: container,
 		BeforeRun: func(_ DiContainer) error { return nil },
+		onStops:   []ApplicationStopHandle{},
 	}, nil
 }
 
+// 注册停止钩子，关闭时按注册的逆序执行。
+func (app *Application) OnStop(handles ...ApplicationStopHandle) {
+	app.onStops = append(app.onStops, handles...)
+}
+
 type ApplicationRunDi struct {
 	dig.In
-	Logger *zerolog.Logger
-	Server *http.Server
-	Queue  *TaskQueue `optional:"true"`
+	Logger     *zerolog.Logger
+	Server     *http.Server
+	Conf       *HttpServerConf `optional:"true"`
+	Queue      *TaskQueue      `optional:"true"`
+	Admin      *AdminRouter    `optional:"true"`
+	Health     *HealthRegistry `optional:"true"`
+	Tracing    *Tracing        `optional:"true"`
+	Lifecycles []Lifecycle     `group:"lifecycle"`
 }
 
 func (app *Application) Run() error {
+	return app.RunContext(context.Background())
+}
+
+// 运行直到 ctx 取消或收到 SIGINT/SIGTERM，然后优雅关闭。
+func (app *Application) RunContext(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	return app.container.Invoke(func(di ApplicationRunDi) error {
 		// 前切入点
 		if err := app.BeforeRun(app.container); err != nil {
 			return err
 		}
 
-		// 队列服务
-		if di.Queue != nil {
-			di.Logger.Info().Str("action", "启动队列...").Msg("[TASK]")
-			err := di.Queue.Run()
+		logConfsOnStart(di.Logger)
+
+		// 生命周期组件
+		lifecycles := sortLifecycles(di.Lifecycles)
+
+		// 链路追踪最先启动、最后停止，停止时导出剩余的 span 。
+		if di.Tracing != nil {
+			lifecycles = append([]Lifecycle{di.Tracing}, lifecycles...)
+		}
+
+		// HTTP 跳转 HTTPS
+		if di.Conf != nil && di.Conf.TlsRedirectPort != nil && di.Server.TLSConfig != nil {
+			_, port, err := net.SplitHostPort(di.Server.Addr)
+			if err != nil {
+				return err
+			}
+			httpsPort, err := strconv.Atoi(port)
 			if err != nil {
 				return err
 			}
+			lifecycles = append(lifecycles, NewHttpRedirectServer(
+				di.Logger,
+				GetOrDefault(di.Conf.Host, "127.0.0.1"),
+				*di.Conf.TlsRedirectPort,
+				uint16(httpsPort),
+			))
+		}
+
+		// 管理端口
+		if di.Admin != nil {
+			lifecycles = append(lifecycles, di.Admin.NewServer(di.Logger))
+		}
+
+		// 队列服务
+		if di.Queue != nil {
+			if !containsLifecycle(lifecycles, di.Queue) {
+				lifecycles = append(lifecycles, di.Queue)
+			}
 		} else {
 			di.Logger.Info().Str("action", "没有启动队列").Msg("[TASK]")
 		}
+
+		started := []Lifecycle{}
+		for _, lifecycle := range lifecycles {
+			name := LifecycleName(lifecycle)
+			di.Logger.Info().Str("action", "启动组件").Str("name", name).Msg("[APP]")
+			if err := lifecycle.Start(ctx); err != nil {
+				err = fmt.Errorf("组件 %s 启动失败: %w", name, err)
+				di.Logger.Error().Str("action", "启动组件出错").Str("name", name).Err(err).Msg("[APP]")
+				return errors.Join(err, app.shutdown(di, started))
+			}
+			started = append(started, lifecycle)
+		}
+
+		listeners, err := NewHttpListeners(di.Server, di.Conf)
+		if err != nil {
+			return errors.Join(err, app.shutdown(di, started))
+		}
 		di.Logger.Info().Str("action", "启动服务器").Msg("[HTTP]")
-		return di.Server.ListenAndServe()
+		serveChan := make(chan error, len(listeners))
+		for _, listener := range listeners {
+			di.Logger.Info().
+				Str("action", "服务器监听").
+				Str("network", listener.Addr().Network()).
+				Str("address", listener.Addr().String()).
+				Msg("[HTTP]")
+			go func(listener net.Listener) {
+				if di.Server.TLSConfig != nil {
+					serveChan <- di.Server.ServeTLS(listener, "", "")
+				} else {
+					serveChan <- di.Server.Serve(listener)
+				}
+			}(listener)
+		}
+
+		var serveErr error
+		select {
+		case <-ctx.Done():
+			di.Logger.Info().Str("action", "收到停止信号").Msg("[APP]")
+		case err := <-serveChan:
+			if !errors.Is(err, http.ErrServerClosed) {
+				serveErr = err
+			}
+		}
+
+		return errors.Join(serveErr, app.shutdown(di, started))
 	})
 }
+
+func (app *Application) shutdown(di ApplicationRunDi, started []Lifecycle) error {
+	// 先让 readyz 失败，再关闭服务器。
+	if di.Health != nil {
+		di.Health.drain()
+	}
+
+	timeout := 10 * time.Second
+	if di.Conf != nil {
+		timeout = GetOrDefault(di.Conf.ShutdownTimeout, timeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	errs := []error{}
+
+	di.Logger.Info().Str("action", "关闭服务器").Dur("timeout", timeout).Msg("[HTTP]")
+	if err := di.Server.Shutdown(ctx); err != nil {
+		di.Logger.Error().Str("action", "关闭服务器出错").Err(err).Msg("[HTTP]")
+		errs = append(errs, err)
+	}
+
+	for i := len(started) - 1; i >= 0; i-- {
+		name := LifecycleName(started[i])
+		di.Logger.Info().Str("action", "停止组件").Str("name", name).Msg("[APP]")
+		if err := started[i].Stop(ctx); err != nil {
+			di.Logger.Error().Str("action", "停止组件出错").Str("name", name).Err(err).Msg("[APP]")
+			errs = append(errs, fmt.Errorf("组件 %s 停止失败: %w", name, err))
+		}
+	}
+
+	for i := len(app.onStops) - 1; i >= 0; i-- {
+		if err := app.onStops[i](ctx, app.container); err != nil {
+			di.Logger.Error().Str("action", "停止钩子出错").Int("index", i).Err(err).Msg("[APP]")
+			errs = append(errs, err)
+		}
+	}
+
+	di.Logger.Info().Str("action", "已停止").Msg("[APP]")
+	return errors.Join(errs...)
+}
+
+func containsLifecycle(items []Lifecycle, v Lifecycle) bool {
+	for _, item := range items {
+		if unwrapLifecycle(item) == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package cjungo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 记录启动、停止顺序的生命周期组件。
+type testLifecycle struct {
+	name    string
+	events  *testEvents
+	started chan struct{}
+	stop    func(ctx context.Context) error
+}
+
+func (lifecycle *testLifecycle) Name() string {
+	return lifecycle.name
+}
+
+func (lifecycle *testLifecycle) Start(ctx context.Context) error {
+	lifecycle.events.add("start " + lifecycle.name)
+	if lifecycle.started != nil {
+		close(lifecycle.started)
+	}
+	return nil
+}
+
+func (lifecycle *testLifecycle) Stop(ctx context.Context) error {
+	lifecycle.events.add("stop " + lifecycle.name)
+	if lifecycle.stop != nil {
+		return lifecycle.stop(ctx)
+	}
+	return nil
+}
+
+type testEvents struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (events *testEvents) add(event string) {
+	events.mutex.Lock()
+	defer events.mutex.Unlock()
+	events.events = append(events.events, event)
+}
+
+func (events *testEvents) get() []string {
+	events.mutex.Lock()
+	defer events.mutex.Unlock()
+	return append([]string{}, events.events...)
+}
+
+// 监听随机端口、不输出日志的应用。
+func newTestApplication(t *testing.T, shutdownTimeout time.Duration, lifecycles ...*testLifecycle) *Application {
+	t.Helper()
+	app, err := NewApplication(func(container DiContainer) error {
+		if err := container.Provides(
+			func() *LoggerConf { return &LoggerConf{} },
+			func() *HttpServerConf {
+				return &HttpServerConf{
+					Listens:         []string{"127.0.0.1:0"},
+					ShutdownTimeout: &shutdownTimeout,
+				}
+			},
+			func(router HttpRouter) http.Handler { return router.GetHandler() },
+		); err != nil {
+			return err
+		}
+		for _, lifecycle := range lifecycles {
+			lifecycle := lifecycle
+			// 直接提供到值组，按名称启动。
+			if err := container.Provide(func() LifecycleOut { return LifecycleOut{Lifecycle: lifecycle} }); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func waitRun(t *testing.T, done <-chan error, timeout time.Duration) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		t.Fatal("应用没有停止")
+		return nil
+	}
+}
+
+func TestApplicationRunContextCancel(t *testing.T) {
+	events := &testEvents{}
+	started := make(chan struct{})
+	app := newTestApplication(t, time.Second,
+		&testLifecycle{name: "a", events: events},
+		&testLifecycle{name: "b", events: events, started: started},
+	)
+	app.OnStop(
+		func(ctx context.Context, container DiContainer) error {
+			events.add("onStop 1")
+			return nil
+		},
+		func(ctx context.Context, container DiContainer) error {
+			events.add("onStop 2")
+			return nil
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.RunContext(ctx) }()
+	<-started
+	cancel()
+	if err := waitRun(t, done, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// 组件按启动的逆序停止，停止钩子在组件之后按注册的逆序执行。
+	want := []string{"start a", "start b", "stop b", "stop a", "onStop 2", "onStop 1"}
+	got := events.get()
+	if len(got) != len(want) {
+		t.Fatalf("事件 %v，应为 %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("事件 %v，应为 %v", got, want)
+		}
+	}
+}
+
+func TestApplicationRunSignal(t *testing.T) {
+	events := &testEvents{}
+	started := make(chan struct{})
+	app := newTestApplication(t, time.Second, &testLifecycle{name: "a", events: events, started: started})
+
+	done := make(chan error, 1)
+	go func() { done <- app.RunContext(context.Background()) }()
+	// 组件启动时已注册信号处理，SIGTERM 不会结束测试进程。
+	<-started
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitRun(t, done, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := events.get(); len(got) != 2 || got[1] != "stop a" {
+		t.Fatalf("事件 %v", got)
+	}
+}
+
+func TestApplicationShutdownTimeout(t *testing.T) {
+	events := &testEvents{}
+	started := make(chan struct{})
+	app := newTestApplication(t, 100*time.Millisecond,
+		&testLifecycle{name: "slow", events: events, started: started, stop: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	)
+	isHookCalled := false
+	app.OnStop(func(ctx context.Context, container DiContainer) error {
+		isHookCalled = true
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.RunContext(ctx) }()
+	<-started
+	begin := time.Now()
+	cancel()
+	err := waitRun(t, done, 5*time.Second)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("应返回超时错误，实际为 %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed > 2*time.Second {
+		t.Fatalf("关闭用时 %v ，没有按 ShutdownTimeout 结束", elapsed)
+	}
+	// 组件超时后仍执行停止钩子。
+	if !isHookCalled {
+		t.Fatal("没有执行停止钩子")
+	}
+}
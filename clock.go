@@ -0,0 +1,100 @@
+package cjungo
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 时钟，可注入 ManualClock 以便在测试中控制时间。
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) ClockTimer
+}
+
+type ClockTimer interface {
+	Stop() bool
+}
+
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (SystemClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	return time.AfterFunc(d, f)
+}
+
+// 手动推进的时钟，定时器在 Advance 中按到期顺序同步触发。
+type ManualClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	clock *ManualClock
+	at    time.Time
+	f     func()
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (clock *ManualClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.now
+}
+
+func (clock *ManualClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	timer := &manualTimer{
+		clock: clock,
+		at:    clock.now.Add(d),
+		f:     f,
+	}
+	clock.timers = append(clock.timers, timer)
+	return timer
+}
+
+func (clock *ManualClock) Advance(d time.Duration) {
+	clock.Set(clock.Now().Add(d))
+}
+
+func (clock *ManualClock) Set(now time.Time) {
+	for {
+		clock.mutex.Lock()
+		sort.SliceStable(clock.timers, func(i, j int) bool {
+			return clock.timers[i].at.Before(clock.timers[j].at)
+		})
+		if len(clock.timers) == 0 || clock.timers[0].at.After(now) {
+			clock.now = now
+			clock.mutex.Unlock()
+			return
+		}
+		timer := clock.timers[0]
+		clock.timers = clock.timers[1:]
+		if timer.at.After(clock.now) {
+			clock.now = timer.at
+		}
+		clock.mutex.Unlock()
+		timer.f()
+	}
+}
+
+func (timer *manualTimer) Stop() bool {
+	clock := timer.clock
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	for i, t := range clock.timers {
+		if t == timer {
+			clock.timers = append(clock.timers[:i], clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
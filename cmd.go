@@ -27,21 +27,47 @@ func RunCommand[T any](
 	}
 	logPath := fmt.Sprintf("./log/%s.log", name)
 	os.Setenv("CJUNGO_LOG_FILENAME", logPath)
-	if err := LoadEnv(); err != nil {
+	container, err := newCommandContainer[T](providers...)
+	if err != nil {
 		return err
 	}
 
+	return container.Invoke(func(logger *zerolog.Logger) error {
+		logger.
+			Info().
+			Str("action", "开始").
+			Str("name", name).
+			Msg("[CMD]")
+		logConfsOnStart(logger)
+		if err := container.Invoke(runner); err != nil {
+			return err
+		}
+		logger.
+			Info().
+			Str("action", "完成").
+			Str("name", name).
+			Msg("[CMD]")
+		return nil
+	})
+}
+
+// 加载 .env ，提供日志、providers 和命令行参数 T 。
+func newCommandContainer[T any](providers ...any) (*DiSimpleContainer, error) {
+	if err := LoadEnv(); err != nil {
+		return nil, err
+	}
+
 	container := &DiSimpleContainer{
 		Container: dig.New(),
 	}
 	// 日志
 	if err := container.Provides(NewLogger, LoadLoggerConfFromEnv); err != nil {
-		return err
+		return nil, err
 	}
 
 	// 提供
 	if err := container.Provides(providers...); err != nil {
-		return err
+		return nil, err
 	}
 
 	// 参数
@@ -55,25 +81,10 @@ func RunCommand[T any](
 		}
 		return &args, nil
 	}); err != nil {
-		return err
+		return nil, err
 	}
 
-	return container.Invoke(func(logger *zerolog.Logger) error {
-		logger.
-			Info().
-			Str("action", "开始").
-			Str("name", name).
-			Msg("[CMD]")
-		if err := container.Invoke(runner); err != nil {
-			return err
-		}
-		logger.
-			Info().
-			Str("action", "完成").
-			Str("name", name).
-			Msg("[CMD]")
-		return nil
-	})
+	return container, nil
 }
 
 func GetFuncName(v any) string {
@@ -0,0 +1,468 @@
+package cjungo
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置加载器，按结构体标签填充配置：
+//
+//	env:"CJUNGO_HTTP_PORT" default:"12345" required:"true" min:"1" max:"65535"
+//
+// 取值优先级：环境变量 > 配置文件（后面的文件覆盖前面的） > default 标签。
+// 配置文件支持 YAML、JSON、TOML ，键可以是环境变量名，也可以是嵌套结构，
+// 如 http.port 对应 CJUNGO_HTTP_PORT 。
+type ConfLoader struct {
+	files       []string
+	values      map[string]string
+	sources     map[string]string // 键所在的配置文件
+	env         *envSnapshot      // 重新加载时使用尚未写入进程的环境变量
+	defaultOnly bool
+}
+
+// 配置结构体实现该接口时，加载后调用校验。
+type ConfValidator interface {
+	Validate() error
+}
+
+const CONF_ENV_PREFIX = "CJUNGO_"
+
+func NewConfLoader(files ...string) (*ConfLoader, error) {
+	loader := &ConfLoader{
+		files:   files,
+		values:  map[string]string{},
+		sources: map[string]string{},
+	}
+	for _, file := range files {
+		values, err := readConfFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range values {
+			loader.values[k] = v
+			loader.sources[k] = file
+		}
+	}
+	return loader, nil
+}
+
+var (
+	defaultConfLoader      *ConfLoader
+	defaultConfLoaderMutex sync.Mutex
+)
+
+// 默认加载器，配置文件由环境变量 CJUNGO_CONFIG_FILE 指定，多个文件用逗号分隔。
+func DefaultConfLoader() (*ConfLoader, error) {
+	defaultConfLoaderMutex.Lock()
+	defer defaultConfLoaderMutex.Unlock()
+	if defaultConfLoader == nil {
+		loader, err := newDefaultConfLoader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defaultConfLoader = loader
+	}
+	return defaultConfLoader, nil
+}
+
+// 重新读取默认加载器的配置文件，失败时保留原来的加载器。
+func ReloadConf() error {
+	loader, err := newDefaultConfLoader(nil)
+	if err != nil {
+		return err
+	}
+	setDefaultConfLoader(loader)
+	return nil
+}
+
+func setDefaultConfLoader(loader *ConfLoader) {
+	defaultConfLoaderMutex.Lock()
+	defaultConfLoader = loader
+	defaultConfLoaderMutex.Unlock()
+}
+
+// env 不为空时从中读取环境变量，否则读取进程环境变量。
+func newDefaultConfLoader(env *envSnapshot) (*ConfLoader, error) {
+	getenv := os.Getenv
+	if env != nil {
+		getenv = func(name string) string { return env.env[name] }
+	}
+	files := []string{}
+	for _, file := range strings.Split(getenv("CJUNGO_CONFIG_FILE"), ",") {
+		if file = strings.TrimSpace(file); len(file) > 0 {
+			files = append(files, file)
+		}
+	}
+	loader, err := NewConfLoader(files...)
+	if err != nil {
+		return nil, err
+	}
+	loader.env = env
+	return loader, nil
+}
+
+// 只使用 default 标签的配置，不读取环境变量和配置文件。
+func NewDefaultConf[T any]() *T {
+	conf := new(T)
+	loader := &ConfLoader{defaultOnly: true}
+	if err := loader.Load(conf); err != nil {
+		panic(err) // default 标签有误
+	}
+	return conf
+}
+
+// 使用默认加载器填充 conf 。
+func LoadConf(conf any) error {
+	loader, err := DefaultConfLoader()
+	if err != nil {
+		return err
+	}
+	return loader.Load(conf)
+}
+
+// 加载器读取的配置文件。
+func (loader *ConfLoader) Files() []string {
+	return append([]string{}, loader.files...)
+}
+
+func (loader *ConfLoader) Lookup(name string) (string, bool, error) {
+	text, _, _, ok, err := loader.lookup(name)
+	return text, ok, err
+}
+
+// 同 Lookup ，另外返回来源和所在文件。
+func (loader *ConfLoader) lookup(name string) (string, string, string, bool, error) {
+	if loader.defaultOnly {
+		return "", "", "", false, nil
+	}
+	lookupEnv := lookupEnvSource
+	if loader.env != nil {
+		lookupEnv = loader.env.lookup
+	}
+	if v, file, ok, err := lookupEnv(name); err != nil || ok {
+		return v, CONF_SOURCE_ENV, file, ok, err
+	}
+	keys := []string{name}
+	if strings.HasPrefix(name, CONF_ENV_PREFIX) {
+		keys = append(keys, strings.TrimPrefix(name, CONF_ENV_PREFIX))
+	}
+	for _, key := range keys {
+		if v, ok := loader.values[key]; ok {
+			return v, CONF_SOURCE_FILE, loader.sources[key], true, nil
+		}
+	}
+	return "", "", "", false, nil
+}
+
+// 填充 conf（结构体指针），所有错误一并返回。
+func (loader *ConfLoader) Load(conf any) error {
+	fields, errs := loader.loadFields(conf)
+	if validator, ok := conf.(ConfValidator); ok {
+		if err := validator.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	recordConf(conf, fields)
+	return nil
+}
+
+// 只填充 conf ，不校验、不记录。
+func (loader *ConfLoader) loadFields(conf any) ([]confField, []error) {
+	v := reflect.ValueOf(conf)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, []error{fmt.Errorf("配置必须是结构体指针: %T", conf)}
+	}
+	fields := []confField{}
+	errs := loader.loadStruct(v.Elem(), "", nil, &fields)
+	return fields, errs
+}
+
+func (loader *ConfLoader) loadStruct(v reflect.Value, prefix string, index []int, fields *[]confField) []error {
+	errs := []error{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+		name, hasEnv := field.Tag.Lookup("env")
+		if !hasEnv {
+			if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+				errs = append(errs, loader.loadStruct(fv, prefix+field.Name+".", fieldIndex, fields)...)
+			}
+			continue
+		}
+
+		text, source, file, ok, err := loader.lookup(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			if field.Tag.Get("required") == "true" && !loader.defaultOnly {
+				errs = append(errs, fmt.Errorf("配置 %s 不能为空", name))
+				continue
+			}
+			source = CONF_SOURCE_UNSET
+			if text, ok = field.Tag.Lookup("default"); ok {
+				source = CONF_SOURCE_DEFAULT
+			}
+		}
+		*fields = append(*fields, confField{
+			name:   prefix + field.Name,
+			env:    name,
+			source: source,
+			file:   file,
+			index:  fieldIndex,
+			secret: field.Tag.Get("secret") == "true",
+		})
+		if !ok {
+			continue
+		}
+		if err := setConfValue(fv, text); err != nil {
+			errs = append(errs, fmt.Errorf("配置 %s 的值 %q 无效: %v", name, text, err))
+			continue
+		}
+		if err := checkConfRange(fv, field.Tag); err != nil {
+			errs = append(errs, fmt.Errorf("配置 %s %v", name, err))
+		}
+	}
+	return errs
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	urlType      = reflect.TypeOf(url.URL{})
+	byteSizeType = reflect.TypeOf(ByteSize(0))
+)
+
+func setConfValue(v reflect.Value, text string) error {
+	if v.Kind() == reflect.Pointer {
+		p := reflect.New(v.Type().Elem())
+		if err := setConfValue(p.Elem(), text); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	if v.Type() == urlType {
+		u, err := url.Parse(text)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	}
+	if unmarshaler, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(text))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(text, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		items := splitConfList(text)
+		s := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setConfValue(s.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		for _, item := range splitConfList(text) {
+			key, value, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("%s 应为 key=value", item)
+			}
+			k := reflect.New(v.Type().Key()).Elem()
+			if err := setConfValue(k, strings.TrimSpace(key)); err != nil {
+				return err
+			}
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := setConfValue(e, strings.TrimSpace(value)); err != nil {
+				return err
+			}
+			m.SetMapIndex(k, e)
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("不支持的类型 %s", v.Type())
+	}
+	return nil
+}
+
+func splitConfList(text string) []string {
+	result := []string{}
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func checkConfRange(v reflect.Value, tag reflect.StructTag) error {
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	var n float64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		return nil
+	}
+	parse := func(text string) (float64, error) {
+		switch v.Type() {
+		case durationType:
+			d, err := time.ParseDuration(text)
+			return float64(d), err
+		case byteSizeType:
+			size, err := ParseByteSize(text)
+			return float64(size), err
+		}
+		return strconv.ParseFloat(text, 64)
+	}
+	if text, ok := tag.Lookup("min"); ok {
+		min, err := parse(text)
+		if err != nil {
+			return fmt.Errorf("min 标签 %q 无效", text)
+		}
+		if n < min {
+			return fmt.Errorf("不能小于 %s", text)
+		}
+	}
+	if text, ok := tag.Lookup("max"); ok {
+		max, err := parse(text)
+		if err != nil {
+			return fmt.Errorf("max 标签 %q 无效", text)
+		}
+		if n > max {
+			return fmt.Errorf("不能大于 %s", text)
+		}
+	}
+	return nil
+}
+
+func readConfFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(content, &data)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &data)
+	case ".toml":
+		err = toml.Unmarshal(content, &data)
+	default:
+		return nil, fmt.Errorf("不支持的配置文件格式: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+	}
+	result := map[string]string{}
+	flattenConf("", data, result)
+	return result, nil
+}
+
+// 嵌套的键用 _ 连接并转为大写，如 http.read-timeout 转为 HTTP_READ_TIMEOUT 。
+func flattenConf(prefix string, data map[string]any, result map[string]string) {
+	for k, v := range data {
+		key := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(k))
+		if len(prefix) > 0 {
+			key = prefix + "_" + key
+		}
+		// 嵌套结构同时保留 k=v 形式，供 map 类型的字段使用。
+		result[key] = formatConfValue(v)
+		if m, ok := v.(map[string]any); ok {
+			flattenConf(key, m, result)
+		}
+	}
+}
+
+func formatConfValue(v any) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case time.Time:
+		return value.Format(time.RFC3339)
+	case []any:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = formatConfValue(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]any:
+		items := []string{}
+		for k, item := range value {
+			items = append(items, fmt.Sprintf("%s=%s", k, formatConfValue(item)))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,241 @@
+package cjungo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// 配置项的来源
+const (
+	CONF_SOURCE_ENV     = "env"     // 环境变量（包括 .env 文件和 _FILE 文件）
+	CONF_SOURCE_FILE    = "file"    // 配置文件
+	CONF_SOURCE_DEFAULT = "default" // default 标签或程序默认值
+	CONF_SOURCE_UNSET   = "unset"   // 未配置
+)
+
+const CONF_SECRET_MASK = "******"
+
+type confField struct {
+	name   string
+	env    string
+	source string
+	file   string
+	index  []int
+	secret bool
+}
+
+type confRecord struct {
+	name   string
+	conf   reflect.Value
+	fields []confField
+}
+
+var (
+	confRecords     = map[reflect.Type]*confRecord{}
+	confRecordOrder = []reflect.Type{}
+	confRecordMutex sync.Mutex
+)
+
+// 记录加载过的配置，同一类型只保留最后一次。
+func recordConf(conf any, fields []confField) {
+	v := reflect.ValueOf(conf)
+	confRecordMutex.Lock()
+	defer confRecordMutex.Unlock()
+	if _, ok := confRecords[v.Type()]; !ok {
+		confRecordOrder = append(confRecordOrder, v.Type())
+	}
+	confRecords[v.Type()] = &confRecord{
+		name:   GetTypeName(conf),
+		conf:   v,
+		fields: fields,
+	}
+}
+
+type ConfFieldDump struct {
+	Name   string `json:"name"`
+	Env    string `json:"env"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	File   string `json:"file,omitempty"`
+}
+
+type ConfDump struct {
+	Name   string          `json:"name"`
+	Fields []ConfFieldDump `json:"fields"`
+}
+
+// 所有通过加载器加载过的配置的当前值，secret:"true" 的字段会被遮盖。
+func DumpConfs() []ConfDump {
+	confRecordMutex.Lock()
+	defer confRecordMutex.Unlock()
+	result := []ConfDump{}
+	for _, t := range confRecordOrder {
+		record := confRecords[t]
+		dump := ConfDump{
+			Name:   record.name,
+			Fields: []ConfFieldDump{},
+		}
+		for _, field := range record.fields {
+			v := record.conf.Elem().FieldByIndex(field.index)
+			value := formatConfField(v)
+			source := field.source
+			// 加载后由程序填充的默认值，如 SqliteConf.Path
+			if source == CONF_SOURCE_UNSET && !v.IsZero() {
+				source = CONF_SOURCE_DEFAULT
+			}
+			if field.secret && len(value) > 0 {
+				value = CONF_SECRET_MASK
+			}
+			dump.Fields = append(dump.Fields, ConfFieldDump{
+				Name:   field.name,
+				Env:    field.env,
+				Value:  value,
+				Source: source,
+				File:   field.file,
+			})
+		}
+		result = append(result, dump)
+	}
+	return result
+}
+
+// 把所有配置输出到日志。
+func LogConfs(logger *zerolog.Logger) {
+	for _, dump := range DumpConfs() {
+		for _, field := range dump.Fields {
+			logger.Info().
+				Str("action", "配置").
+				Str("conf", dump.Name).
+				Str("field", field.Name).
+				Str("env", field.Env).
+				Str("value", field.Value).
+				Str("source", field.Source).
+				Str("file", field.File).
+				Msg("[CONF]")
+		}
+	}
+}
+
+func formatConfField(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch v.Type() {
+	case durationType:
+		return time.Duration(v.Int()).String()
+	case urlType:
+		u := v.Interface().(url.URL)
+		return u.Redacted()
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = formatConfField(v.Index(i))
+		}
+		return strings.Join(items, ",")
+	case reflect.Map:
+		items := []string{}
+		iter := v.MapRange()
+		for iter.Next() {
+			items = append(items, fmt.Sprintf("%s=%s", formatConfField(iter.Key()), formatConfField(iter.Value())))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+type ConfDumpArgs struct {
+	Format string `long:"format" description:"输出格式" choice:"text" choice:"json" default:"text"`
+}
+
+// 打印配置的子命令，loaders 为 Load*FromEnv 这类返回配置的函数，如：
+//
+//	cjungo.RunConfCommand(cjungo.LoadHttpServerConfFromEnv, db.LoadMySqlConfFormEnv)
+func RunConfCommand(loaders ...any) error {
+	// 与 RunCommand 不同，不写日志文件，结果直接输出到 stdout 。
+	container, err := newCommandContainer[ConfDumpArgs](loaders...)
+	if err != nil {
+		return err
+	}
+	// 日志不输出到控制台，避免混入结果。
+	if err := container.Decorate(func(conf *LoggerConf) *LoggerConf {
+		quiet := *conf
+		quiet.IsOutputConsole = false
+		return &quiet
+	}); err != nil {
+		return err
+	}
+
+	// 依赖每个 loader 的返回值，使其全部执行。
+	params := []reflect.Type{reflect.TypeOf(&ConfDumpArgs{})}
+	for _, loader := range loaders {
+		t := reflect.TypeOf(loader)
+		if t.Kind() != reflect.Func || t.NumOut() == 0 {
+			return fmt.Errorf("%s 不是配置加载函数", GetFuncName(loader))
+		}
+		params = append(params, t.Out(0))
+	}
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	runner := reflect.MakeFunc(
+		reflect.FuncOf(params, []reflect.Type{errorType}, false),
+		func(args []reflect.Value) []reflect.Value {
+			err := printConfs(args[0].Interface().(*ConfDumpArgs))
+			result := reflect.New(errorType).Elem()
+			if err != nil {
+				result.Set(reflect.ValueOf(err))
+			}
+			return []reflect.Value{result}
+		},
+	)
+	return container.Invoke(runner.Interface())
+}
+
+func printConfs(args *ConfDumpArgs) error {
+	dumps := DumpConfs()
+	if args.Format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(dumps)
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, dump := range dumps {
+		fmt.Fprintf(writer, "[%s]\n", dump.Name)
+		for _, field := range dump.Fields {
+			source := field.Source
+			if len(field.File) > 0 {
+				source = fmt.Sprintf("%s(%s)", source, field.File)
+			}
+			fmt.Fprintf(writer, "  %s\t%s\t%s\t%s\n", field.Name, field.Env, field.Value, source)
+		}
+	}
+	return writer.Flush()
+}
+
+// 启用 CJUNGO_CONFIG_DUMP 时在启动时把配置输出到日志。
+func logConfsOnStart(logger *zerolog.Logger) {
+	isDump := false
+	if err := GetEnvBool("CJUNGO_CONFIG_DUMP", func(v bool) {
+		isDump = v
+	}); err != nil {
+		logger.Error().Str("action", "读取 CJUNGO_CONFIG_DUMP 失败").Err(err).Msg("[CONF]")
+	}
+	if isDump {
+		LogConfs(logger)
+	}
+}
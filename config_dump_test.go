@@ -0,0 +1,73 @@
+package cjungo
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testDumpConf struct {
+	User    string            `env:"CJUNGO_TEST_DUMP_USER" default:"root"`
+	Pass    string            `env:"CJUNGO_TEST_DUMP_PASS" secret:"true"`
+	Token   *string           `env:"CJUNGO_TEST_DUMP_TOKEN" secret:"true"`
+	Headers map[string]string `env:"CJUNGO_TEST_DUMP_HEADERS" secret:"true"`
+	Dsn     *url.URL          `env:"CJUNGO_TEST_DUMP_DSN"`
+	Empty   string            `env:"CJUNGO_TEST_DUMP_EMPTY" secret:"true"`
+}
+
+func findTestConfDump(t *testing.T, name string) map[string]ConfFieldDump {
+	t.Helper()
+	for _, dump := range DumpConfs() {
+		if dump.Name == name {
+			fields := map[string]ConfFieldDump{}
+			for _, field := range dump.Fields {
+				fields[field.Name] = field
+			}
+			return fields
+		}
+	}
+	t.Fatalf("没有 %s 的记录", name)
+	return nil
+}
+
+func TestDumpConfsMaskSecret(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "token")
+	writeTestEnvFiles(t, map[string]string{secret: "file-token\n"})
+	t.Setenv("CJUNGO_TEST_DUMP_PASS", "p@ss")
+	t.Setenv("CJUNGO_TEST_DUMP_TOKEN_FILE", secret)
+	t.Setenv("CJUNGO_TEST_DUMP_HEADERS", "Authorization=Bearer xxx")
+	t.Setenv("CJUNGO_TEST_DUMP_DSN", "mysql://admin:hidden@db:3306/app")
+	loader, err := NewConfLoader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.Load(&testDumpConf{}); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := findTestConfDump(t, "testDumpConf")
+	for name, want := range map[string]ConfFieldDump{
+		"User":    {Value: "root", Source: CONF_SOURCE_DEFAULT},
+		"Pass":    {Value: CONF_SECRET_MASK, Source: CONF_SOURCE_ENV},
+		"Token":   {Value: CONF_SECRET_MASK, Source: CONF_SOURCE_ENV, File: secret},
+		"Headers": {Value: CONF_SECRET_MASK, Source: CONF_SOURCE_ENV},
+		"Empty":   {Value: "", Source: CONF_SOURCE_UNSET}, // 空值不遮盖
+	} {
+		got := fields[name]
+		if got.Value != want.Value || got.Source != want.Source || got.File != want.File {
+			t.Errorf("%s 为 %+v，应为 %+v", name, got, want)
+		}
+	}
+	// URL 中的密码同样不输出。
+	if dsn := fields["Dsn"].Value; strings.Contains(dsn, "hidden") || !strings.Contains(dsn, "admin") {
+		t.Errorf("Dsn 为 %s", dsn)
+	}
+	for _, field := range fields {
+		for _, text := range []string{"p@ss", "file-token", "Bearer"} {
+			if strings.Contains(field.Value, text) {
+				t.Errorf("%s 泄露了 %s", field.Name, text)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package cjungo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testTagConf struct {
+	Host    *string        `env:"CJUNGO_TEST_HOST" default:"127.0.0.1"`
+	Port    *uint16        `env:"CJUNGO_TEST_PORT" default:"8080" min:"1" max:"9000"`
+	Timeout *time.Duration `env:"CJUNGO_TEST_TIMEOUT" default:"10s" min:"1s"`
+	Name    string         `env:"CJUNGO_TEST_NAME" required:"true"`
+	IsDebug bool           `env:"CJUNGO_TEST_IS_DEBUG"`
+	Tags    []string       `env:"CJUNGO_TEST_TAGS"`
+	Limits  map[string]int `env:"CJUNGO_TEST_LIMITS"`
+	Unset   *int           `env:"CJUNGO_TEST_UNSET"`
+	Nested  struct {
+		Ratio float64 `env:"CJUNGO_TEST_NESTED_RATIO" default:"0.5" max:"1"`
+	}
+}
+
+type testValidateConf struct {
+	Count int `env:"CJUNGO_TEST_COUNT" default:"1"`
+}
+
+func (conf *testValidateConf) Validate() error {
+	if conf.Count%2 != 0 {
+		return os.ErrInvalid
+	}
+	return nil
+}
+
+func writeTestConfFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfLoaderTags(t *testing.T) {
+	t.Setenv("CJUNGO_TEST_NAME", "app")
+	t.Setenv("CJUNGO_TEST_PORT", "8081")
+	t.Setenv("CJUNGO_TEST_IS_DEBUG", "true")
+	t.Setenv("CJUNGO_TEST_TAGS", "a, b,,c")
+	t.Setenv("CJUNGO_TEST_LIMITS", "x=1,y=2")
+	loader, err := NewConfLoader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &testTagConf{}
+	if err := loader.Load(conf); err != nil {
+		t.Fatal(err)
+	}
+	if *conf.Host != "127.0.0.1" || *conf.Port != 8081 || *conf.Timeout != 10*time.Second {
+		t.Fatalf("默认值或环境变量有误: %s %d %v", *conf.Host, *conf.Port, *conf.Timeout)
+	}
+	if conf.Name != "app" || !conf.IsDebug || conf.Unset != nil || conf.Nested.Ratio != 0.5 {
+		t.Fatalf("配置有误: %+v", conf)
+	}
+	if strings.Join(conf.Tags, "|") != "a|b|c" || conf.Limits["x"] != 1 || conf.Limits["y"] != 2 {
+		t.Fatalf("列表或映射有误: %v %v", conf.Tags, conf.Limits)
+	}
+}
+
+func TestConfLoaderTagErrors(t *testing.T) {
+	// 所有错误一并返回。
+	t.Setenv("CJUNGO_TEST_PORT", "9001")
+	t.Setenv("CJUNGO_TEST_TIMEOUT", "500ms")
+	t.Setenv("CJUNGO_TEST_NESTED_RATIO", "abc")
+	loader, err := NewConfLoader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = loader.Load(&testTagConf{})
+	if err == nil {
+		t.Fatal("应加载失败")
+	}
+	for _, text := range []string{
+		"CJUNGO_TEST_NAME 不能为空",
+		"CJUNGO_TEST_PORT 不能大于 9000",
+		"CJUNGO_TEST_TIMEOUT 不能小于 1s",
+		"CJUNGO_TEST_NESTED_RATIO 的值 \"abc\" 无效",
+	} {
+		if !strings.Contains(err.Error(), text) {
+			t.Errorf("错误 %q 中没有 %q", err, text)
+		}
+	}
+
+	t.Setenv("CJUNGO_TEST_PORT", "0")
+	t.Setenv("CJUNGO_TEST_NAME", "app")
+	t.Setenv("CJUNGO_TEST_TIMEOUT", "1s")
+	t.Setenv("CJUNGO_TEST_NESTED_RATIO", "1")
+	if err := loader.Load(&testTagConf{}); err == nil || !strings.Contains(err.Error(), "不能小于 1") {
+		t.Fatalf("错误为 %v", err)
+	}
+
+	if err := loader.Load(testTagConf{}); err == nil {
+		t.Fatal("非指针应加载失败")
+	}
+}
+
+func TestConfLoaderValidate(t *testing.T) {
+	loader, err := NewConfLoader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.Load(&testValidateConf{}); err == nil {
+		t.Fatal("应校验失败")
+	}
+	t.Setenv("CJUNGO_TEST_COUNT", "2")
+	if err := loader.Load(&testValidateConf{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfLoaderFileLayering(t *testing.T) {
+	yamlFile := writeTestConfFile(t, "base.yaml", `
+test:
+  host: yaml.local
+  port: 1000
+  name: from-yaml
+  tags: [a, b]
+  limits:
+    x: 1
+    y: 2
+`)
+	jsonFile := writeTestConfFile(t, "override.json", `{"CJUNGO_TEST_PORT": 2000, "test": {"is-debug": true}}`)
+	tomlFile := writeTestConfFile(t, "local.toml", `
+[test]
+name = "from-toml"
+timeout = "30s"
+`)
+	t.Setenv("CJUNGO_TEST_HOST", "env.local")
+
+	// 后面的文件覆盖前面的，环境变量优先于文件。
+	loader, err := NewConfLoader(yamlFile, jsonFile, tomlFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &testTagConf{}
+	if err := loader.Load(conf); err != nil {
+		t.Fatal(err)
+	}
+	if *conf.Host != "env.local" {
+		t.Errorf("Host 为 %s，环境变量应优先", *conf.Host)
+	}
+	if *conf.Port != 2000 {
+		t.Errorf("Port 为 %d，JSON 应覆盖 YAML", *conf.Port)
+	}
+	if conf.Name != "from-toml" || *conf.Timeout != 30*time.Second {
+		t.Errorf("Name 为 %s ，Timeout 为 %v ，TOML 应覆盖", conf.Name, *conf.Timeout)
+	}
+	if !conf.IsDebug || strings.Join(conf.Tags, "|") != "a|b" || conf.Limits["y"] != 2 {
+		t.Errorf("配置有误: %+v", conf)
+	}
+	if conf.Nested.Ratio != 0.5 {
+		t.Errorf("Ratio 为 %v，应为默认值", conf.Nested.Ratio)
+	}
+	if files := loader.Files(); len(files) != 3 || files[2] != tomlFile {
+		t.Errorf("配置文件 %v", files)
+	}
+}
+
+func TestConfLoaderFileErrors(t *testing.T) {
+	if _, err := NewConfLoader(writeTestConfFile(t, "conf.ini", "a=1")); err == nil {
+		t.Error("不支持的格式应失败")
+	}
+	if _, err := NewConfLoader(writeTestConfFile(t, "conf.json", "{")); err == nil {
+		t.Error("格式错误应失败")
+	}
+	if _, err := NewConfLoader(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("文件不存在应失败")
+	}
+}
+
+func TestNewDefaultConf(t *testing.T) {
+	// 只使用 default 标签，忽略环境变量和 required 。
+	t.Setenv("CJUNGO_TEST_PORT", "1234")
+	conf := NewDefaultConf[testTagConf]()
+	if *conf.Port != 8080 || *conf.Host != "127.0.0.1" || len(conf.Name) != 0 {
+		t.Fatalf("配置有误: %+v", conf)
+	}
+}
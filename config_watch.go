@@ -0,0 +1,313 @@
+package cjungo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.uber.org/dig"
+)
+
+type ConfWatchConf struct {
+	Interval *time.Duration `env:"CJUNGO_CONFIG_WATCH_INTERVAL" min:"0s"` // 检查文件修改的间隔，为 0 时只响应 SIGHUP
+}
+
+// 配置变更事件。
+type ConfChange[T any] struct {
+	Old *T
+	New *T
+}
+
+type confSubscriber interface {
+	prepare(loader *ConfLoader) (bool, error) // 加载并校验新配置，返回是否有变化
+	commit()
+	rollback()
+}
+
+type confSubscription[T any] struct {
+	loaded     *T // 上次从环境变量、配置文件加载的结果
+	current    *T // 生效的配置
+	nextLoaded *T
+	next       *T
+	fields     []confField
+	onChange   func(ConfChange[T])
+}
+
+// 只把加载结果有变化的字段应用到生效的配置上，代码中设置的其他字段保持不变。
+func (s *confSubscription[T]) prepare(loader *ConfLoader) (bool, error) {
+	loaded := new(T)
+	fields, errs := loader.loadFields(loaded)
+	if len(errs) > 0 {
+		return false, errors.Join(errs...)
+	}
+	if reflect.DeepEqual(s.loaded, loaded) {
+		return false, nil
+	}
+	next := new(T)
+	*next = *s.current
+	nextValue := reflect.ValueOf(next).Elem()
+	oldValue := reflect.ValueOf(s.loaded).Elem()
+	newValue := reflect.ValueOf(loaded).Elem()
+	for i := 0; i < nextValue.NumField(); i++ {
+		if nextValue.Type().Field(i).IsExported() && !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			nextValue.Field(i).Set(newValue.Field(i))
+		}
+	}
+	if validator, ok := any(next).(ConfValidator); ok {
+		if err := validator.Validate(); err != nil {
+			return false, err
+		}
+	}
+	s.nextLoaded = loaded
+	s.next = next
+	s.fields = fields
+	return true, nil
+}
+
+func (s *confSubscription[T]) commit() {
+	change := ConfChange[T]{Old: s.current, New: s.next}
+	s.loaded = s.nextLoaded
+	s.current = s.next
+	s.nextLoaded = nil
+	s.next = nil
+	recordConf(s.current, s.fields)
+	if !reflect.DeepEqual(change.Old, change.New) {
+		s.onChange(change)
+	}
+}
+
+func (s *confSubscription[T]) rollback() {
+	s.nextLoaded = nil
+	s.next = nil
+}
+
+// 配置监视，收到 SIGHUP 或 .env 、配置文件修改时重新加载，
+// 通知订阅者，加载失败时保留原来的配置。
+type ConfWatcher struct {
+	logger      *zerolog.Logger
+	interval    time.Duration
+	subscribers []confSubscriber
+	stamps      map[string]time.Time
+	mutex       sync.Mutex
+	quit        chan struct{}
+	done        chan struct{}
+}
+
+type ConfWatcherDi struct {
+	dig.In
+	Conf       *ConfWatchConf `optional:"true"`
+	LoggerConf *LoggerConf    `optional:"true"`
+	Logger     *zerolog.Logger
+}
+
+func NewConfWatcher(di ConfWatcherDi) (*ConfWatcher, error) {
+	if di.Conf == nil {
+		di.Conf = &ConfWatchConf{}
+	}
+	watcher := &ConfWatcher{
+		logger:      di.Logger,
+		interval:    GetOrDefault(di.Conf.Interval, 5*time.Second),
+		subscribers: []confSubscriber{},
+		stamps:      map[string]time.Time{},
+	}
+
+	// 日志级别
+	if err := WatchConf(watcher, di.LoggerConf, func(change ConfChange[LoggerConf]) {
+		if change.Old.Level == change.New.Level {
+			return
+		}
+		level := parseLoggerLevel(change.New.Level)
+		zerolog.SetGlobalLevel(level)
+		watcher.logger.Info().Str("action", "日志级别变更").Str("level", level.String()).Msg("[CONF]")
+	}); err != nil {
+		return nil, err
+	}
+
+	return watcher, nil
+}
+
+func LoadConfWatchConfFromEnv(logger *zerolog.Logger) (*ConfWatchConf, error) {
+	logger.Info().Str("action", "通过环境变量配置配置监视").Msg("[CONF]")
+	conf := &ConfWatchConf{}
+	if err := LoadConf(conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// 订阅配置变更，T 为带 env 标签的配置结构体，只在加载结果有变化时回调。
+// current 为组件实际使用的配置（如注入的配置），为空时使用加载的配置；
+// 重新加载时只有环境变量、配置文件中变化的字段会应用到 current 的副本上。
+func WatchConf[T any](watcher *ConfWatcher, current *T, onChange func(ConfChange[T])) error {
+	loader, err := DefaultConfLoader()
+	if err != nil {
+		return err
+	}
+	loaded := new(T)
+	if _, errs := loader.loadFields(loaded); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if current == nil {
+		current = loaded
+	}
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+	watcher.subscribers = append(watcher.subscribers, &confSubscription[T]{
+		loaded:   loaded,
+		current:  current,
+		onChange: onChange,
+	})
+	return nil
+}
+
+// 重新加载配置，先用新的环境变量、配置文件加载并校验所有订阅的配置，
+// 全部成功后才写入环境变量、替换默认加载器并通知，失败时不改动任何状态。
+func (watcher *ConfWatcher) Reload() error {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+
+	// 失败时也更新，避免同一次修改反复重试。
+	watcher.stamps = watcher.readStamps()
+	var env *envSnapshot
+	if IsEnvLoaded() {
+		snapshot, err := readEnvSnapshot()
+		if err != nil {
+			return err
+		}
+		env = snapshot
+	}
+	loader, err := newDefaultConfLoader(env)
+	if err != nil {
+		return err
+	}
+
+	changed := []confSubscriber{}
+	errs := []error{}
+	for _, subscriber := range watcher.subscribers {
+		ok, err := subscriber.prepare(loader)
+		if err != nil {
+			errs = append(errs, err)
+		} else if ok {
+			changed = append(changed, subscriber)
+		}
+	}
+	if len(errs) == 0 && env != nil {
+		if err := env.apply(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		for _, subscriber := range changed {
+			subscriber.rollback()
+		}
+		return errors.Join(errs...)
+	}
+
+	// 环境变量已写入，之后的加载直接读取进程环境变量。
+	loader.env = nil
+	setDefaultConfLoader(loader)
+	watcher.stamps = watcher.readStamps()
+	for _, subscriber := range changed {
+		subscriber.commit()
+	}
+	watcher.logger.Info().Str("action", "重新加载配置").Int("changed", len(changed)).Msg("[CONF]")
+	return nil
+}
+
+func (watcher *ConfWatcher) Name() string {
+	return "ConfWatcher"
+}
+
+func (watcher *ConfWatcher) Start(ctx context.Context) error {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+	if watcher.quit != nil {
+		return nil
+	}
+	watcher.stamps = watcher.readStamps()
+	watcher.quit = make(chan struct{})
+	watcher.done = make(chan struct{})
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func(quit chan struct{}, done chan struct{}) {
+		defer close(done)
+		defer signal.Stop(hup)
+		var tick <-chan time.Time
+		if watcher.interval > 0 {
+			ticker := time.NewTicker(watcher.interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-quit:
+				return
+			case <-hup:
+				watcher.logger.Info().Str("action", "收到 SIGHUP").Msg("[CONF]")
+				watcher.reload()
+			case <-tick:
+				if watcher.isModified() {
+					watcher.logger.Info().Str("action", "配置文件已修改").Msg("[CONF]")
+					watcher.reload()
+				}
+			}
+		}
+	}(watcher.quit, watcher.done)
+
+	watcher.logger.Info().Str("action", "配置监视启动").Dur("interval", watcher.interval).Msg("[CONF]")
+	return nil
+}
+
+func (watcher *ConfWatcher) Stop(ctx context.Context) error {
+	watcher.mutex.Lock()
+	quit, done := watcher.quit, watcher.done
+	watcher.quit, watcher.done = nil, nil
+	watcher.mutex.Unlock()
+	if quit == nil {
+		return nil
+	}
+	close(quit)
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (watcher *ConfWatcher) reload() {
+	if err := watcher.Reload(); err != nil {
+		watcher.logger.Error().Str("action", "重新加载配置失败，保留原配置").Err(err).Msg("[CONF]")
+	}
+}
+
+func (watcher *ConfWatcher) isModified() bool {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+	return !reflect.DeepEqual(watcher.stamps, watcher.readStamps())
+}
+
+// .env 和配置文件的修改时间，不存在的文件记为零值。
+func (watcher *ConfWatcher) readStamps() map[string]time.Time {
+	files := GetEnvFiles()
+	if loader, err := DefaultConfLoader(); err == nil {
+		files = append(files, loader.Files()...)
+	}
+	stamps := map[string]time.Time{}
+	for _, file := range files {
+		if stat, err := os.Stat(file); err == nil {
+			stamps[file] = stat.ModTime()
+		} else {
+			stamps[file] = time.Time{}
+		}
+	}
+	return stamps
+}
@@ -0,0 +1,136 @@
+package cjungo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+type testWatchConf struct {
+	Count int    `env:"CJUNGO_TEST_WATCH_COUNT" default:"2"`
+	Name  string `env:"CJUNGO_TEST_WATCH_NAME"`
+}
+
+func (conf *testWatchConf) Validate() error {
+	if conf.Count%2 != 0 {
+		return os.ErrInvalid
+	}
+	return nil
+}
+
+func newTestConfWatcher(t *testing.T) *ConfWatcher {
+	t.Helper()
+	logger := zerolog.Nop()
+	watcher, err := NewConfWatcher(ConfWatcherDi{Logger: &logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return watcher
+}
+
+func TestConfWatcherKeepInjectedConf(t *testing.T) {
+	root := t.TempDir()
+	writeTestEnvFiles(t, map[string]string{
+		filepath.Join(root, "go.mod"): "module test\n",
+		filepath.Join(root, ".env"):   "CJUNGO_TEST_WATCH_COUNT=2\n",
+	})
+	loadTestEnv(t, root)
+	watcher := newTestConfWatcher(t)
+
+	// 代码中提供的配置，Name 不来自环境变量。
+	changes := []ConfChange[testWatchConf]{}
+	current := &testWatchConf{Count: 4, Name: "code"}
+	if err := WatchConf(watcher, current, func(change ConfChange[testWatchConf]) {
+		changes = append(changes, change)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有变化时不回调，也不覆盖注入的配置。
+	if err := watcher.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("不应回调: %+v", changes)
+	}
+
+	// 只应用变化的字段。
+	writeTestEnvFiles(t, map[string]string{
+		filepath.Join(root, ".env"): "CJUNGO_TEST_WATCH_COUNT=6\n",
+	})
+	if err := watcher.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("回调 %d 次", len(changes))
+	}
+	if old := changes[0].Old; old != current || old.Count != 4 {
+		t.Errorf("旧配置为 %+v", old)
+	}
+	if next := changes[0].New; next.Count != 6 || next.Name != "code" {
+		t.Errorf("新配置为 %+v", next)
+	}
+}
+
+func TestConfWatcherValidateBeforeCommit(t *testing.T) {
+	root := t.TempDir()
+	writeTestEnvFiles(t, map[string]string{
+		filepath.Join(root, "go.mod"): "module test\n",
+		filepath.Join(root, ".env"):   "CJUNGO_TEST_WATCH_COUNT=2\n",
+	})
+	loadTestEnv(t, root)
+	watcher := newTestConfWatcher(t)
+
+	called := 0
+	if err := WatchConf(watcher, nil, func(change ConfChange[testWatchConf]) {
+		called++
+	}); err != nil {
+		t.Fatal(err)
+	}
+	loader, err := DefaultConfLoader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 校验失败时不写入环境变量，也不替换默认加载器。
+	writeTestEnvFiles(t, map[string]string{
+		filepath.Join(root, ".env"): "CJUNGO_TEST_WATCH_COUNT=3\nCJUNGO_TEST_WATCH_NAME=new\n",
+	})
+	if err := watcher.Reload(); err == nil {
+		t.Fatal("应校验失败")
+	}
+	if got := os.Getenv("CJUNGO_TEST_WATCH_COUNT"); got != "2" {
+		t.Errorf("失败后环境变量为 %q", got)
+	}
+	if _, ok := os.LookupEnv("CJUNGO_TEST_WATCH_NAME"); ok {
+		t.Error("失败后不应写入新的环境变量")
+	}
+	if next, _ := DefaultConfLoader(); next != loader {
+		t.Error("失败后不应替换默认加载器")
+	}
+	if called != 0 {
+		t.Fatalf("回调 %d 次", called)
+	}
+
+	// 修正后重新加载成功。
+	writeTestEnvFiles(t, map[string]string{
+		filepath.Join(root, ".env"): "CJUNGO_TEST_WATCH_COUNT=8\n",
+	})
+	if err := watcher.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("CJUNGO_TEST_WATCH_COUNT"); got != "8" || called != 1 {
+		t.Fatalf("环境变量为 %q，回调 %d 次", got, called)
+	}
+}
+
+func TestParseLoggerLevel(t *testing.T) {
+	// 为空时不限制，与 zerolog 的默认全局级别一致。
+	for text, want := range map[string]zerolog.Level{"": zerolog.TraceLevel, "info": zerolog.InfoLevel, "warn": zerolog.WarnLevel} {
+		if got := parseLoggerLevel(text); got != want {
+			t.Errorf("%q 为 %s，应为 %s", text, got, want)
+		}
+	}
+}
@@ -1,18 +1,25 @@
 package cjungo
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 )
 
 type HttpContext interface {
 	echo.Context
 	GetReqID() string
 	GetReqAt() time.Time
+	GetLogger() *zerolog.Logger // 请求日志，带请求 ID 、路由、方法、IP 和认证主体（echo.Context 已有 Logger 方法）
+	GetSubject() string
+	SetSubject(subject string) // 认证后设置主体，同时加入请求日志
 	RespOk() error
 	Resp(any) error
 	RespBad(error) error
@@ -21,8 +28,10 @@ type HttpContext interface {
 
 type HttpSimpleContext struct {
 	echo.Context
-	reqID string
-	reqAt time.Time
+	reqID   string
+	reqAt   time.Time
+	logger  *zerolog.Logger
+	subject string
 }
 
 func (ctx *HttpSimpleContext) RespOk() error {
@@ -46,15 +55,11 @@ func (ctx *HttpSimpleContext) Resp(data any) error {
 }
 
 func (ctx *HttpSimpleContext) RespBad(err error) error {
-	if apiError, ok := err.(*ApiError); ok {
-		return apiError
-	}
-	return &ApiError{
-		Code:     -1,
-		Message:  err.Error(),
-		HttpCode: http.StatusBadRequest,
-		Reason:   err,
+	var apiError *ApiError
+	if errors.As(err, &apiError) {
+		return err
 	}
+	return NewApiError(ERROR_CODE_BAD_REQUEST, err).WithMessage(err.Error())
 }
 
 func (ctx *HttpSimpleContext) RespBadF(format string, data ...any) error {
@@ -69,14 +74,130 @@ func (ctx *HttpSimpleContext) GetReqAt() time.Time {
 	return ctx.reqAt
 }
 
-func ResetContext(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(ctx echo.Context) error {
-		id := uuid.New().String()
-		now := time.Now()
-		return next(&HttpSimpleContext{
-			Context: ctx,
-			reqID:   id,
-			reqAt:   now,
-		})
+func (ctx *HttpSimpleContext) GetLogger() *zerolog.Logger {
+	if ctx.logger == nil {
+		return GetContextLogger(ctx.Request().Context(), &log.Logger)
+	}
+	return ctx.logger
+}
+
+func (ctx *HttpSimpleContext) GetSubject() string {
+	return ctx.subject
+}
+
+func (ctx *HttpSimpleContext) SetSubject(subject string) {
+	ctx.subject = subject
+	ctx.setLogger(ctx.GetLogger().With().Str("subject", subject).Logger())
+}
+
+// 请求日志同时放入请求的 context.Context ，数据库日志、任务等通过 GetContextLogger 获取。
+func (ctx *HttpSimpleContext) setLogger(logger zerolog.Logger) {
+	ctx.logger = &logger
+	req := ctx.Request()
+	ctx.SetRequest(req.WithContext(logger.WithContext(req.Context())))
+}
+
+// context.Context 中的日志，没有时使用 fallback ，并带上 trace ID 。
+func GetContextLogger(ctx context.Context, fallback *zerolog.Logger) *zerolog.Logger {
+	if logger := zerolog.Ctx(ctx); logger != zerolog.DefaultContextLogger && logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	if traceId := GetTraceID(ctx); len(traceId) > 0 {
+		logger := fallback.With().Str("traceId", traceId).Logger()
+		return &logger
 	}
+	return fallback
+}
+
+// 生成请求日志，需在路由匹配后（Use）和链路追踪之后。
+func newRequestLoggerMiddleware(logger *zerolog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, ok := c.(*HttpSimpleContext)
+			if !ok {
+				return next(c)
+			}
+			with := logger.With().
+				Str("reqId", ctx.reqID).
+				Str("route", ctx.Path()).
+				Str("method", ctx.Request().Method).
+				Str("remoteIp", ctx.RealIP())
+			if traceId := GetTraceID(ctx.Request().Context()); len(traceId) > 0 {
+				with = with.Str("traceId", traceId)
+			}
+			ctx.setLogger(with.Logger())
+			return next(ctx)
+		}
+	}
+}
+
+// 请求 ID 生成函数，可以提供 ULID 、雪花算法等实现。
+type ReqIDGenerator func() string
+
+// 按名称取内置的请求 ID 生成函数：uuid 、uuidv7 。
+func GetReqIDGenerator(name string) (ReqIDGenerator, error) {
+	switch name {
+	case "uuid":
+		return func() string {
+			return uuid.New().String()
+		}, nil
+	case "uuidv7":
+		return func() string {
+			if id, err := uuid.NewV7(); err == nil {
+				return id.String()
+			}
+			return uuid.New().String()
+		}, nil
+	default:
+		return nil, fmt.Errorf("不支持的请求 ID 生成方式 %s", name)
+	}
+}
+
+// 上游的请求 ID 只接受可见 ASCII 字符，避免污染日志。
+func isValidReqID(id string) bool {
+	if len(id) == 0 || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// 使用自定义上下文，请求 ID 依次取信任的请求头，都没有时生成，responseHeader 不为空时在响应头返回。
+func NewResetContext(headers []string, responseHeader string, generate ReqIDGenerator) echo.MiddlewareFunc {
+	if generate == nil {
+		generate, _ = GetReqIDGenerator("uuid")
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			id := ""
+			for _, header := range headers {
+				if value := ctx.Request().Header.Get(header); isValidReqID(value) {
+					id = value
+					break
+				}
+			}
+			if len(id) == 0 {
+				id = generate()
+			}
+			if len(responseHeader) > 0 {
+				ctx.Response().Header().Set(responseHeader, id)
+			}
+			now := time.Now()
+			return next(&HttpSimpleContext{
+				Context: ctx,
+				reqID:   id,
+				reqAt:   now,
+			})
+		}
+	}
+}
+
+var resetContext = NewResetContext(nil, "", nil)
+
+func ResetContext(next echo.HandlerFunc) echo.HandlerFunc {
+	return resetContext(next)
 }
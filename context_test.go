@@ -0,0 +1,203 @@
+package cjungo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// 按 message 取日志行。
+func findTestLogLine(t *testing.T, output *bytes.Buffer, message string) map[string]any {
+	t.Helper()
+	for _, line := range strings.Split(output.String(), "\n") {
+		item := map[string]any{}
+		if json.Unmarshal([]byte(line), &item) == nil && item["message"] == message {
+			return item
+		}
+	}
+	t.Fatalf("没有日志 %s: %s", message, output)
+	return nil
+}
+
+func TestRequestLogger(t *testing.T) {
+	output := &bytes.Buffer{}
+	logger := zerolog.New(output)
+	router := NewRouter(NewRouterDi{Logger: &logger})
+	router.GET("/users/:id", func(ctx HttpContext) error {
+		ctx.GetLogger().Info().Msg("before")
+		ctx.SetSubject("alice")
+		ctx.GetLogger().Info().Msg("after")
+		// 数据库、任务等通过 context.Context 取到同一个日志。
+		fallback := zerolog.Nop()
+		GetContextLogger(ctx.Request().Context(), &fallback).Info().Msg("context")
+		return ctx.RespOk()
+	})
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	router.GetHandler().ServeHTTP(rec, req)
+	reqId := rec.Header().Get("X-Request-ID")
+	if len(reqId) == 0 {
+		t.Fatalf("请求 ID 为 %s", reqId)
+	}
+
+	before := findTestLogLine(t, output, "before")
+	if before["reqId"] != reqId || before["route"] != "/users/:id" || before["method"] != http.MethodGet || before["subject"] != nil {
+		t.Fatalf("请求日志为 %v", before)
+	}
+	for _, message := range []string{"after", "context"} {
+		line := findTestLogLine(t, output, message)
+		if line["reqId"] != reqId || line["route"] != "/users/:id" || line["method"] != http.MethodGet || line["subject"] != "alice" {
+			t.Fatalf("%s 日志为 %v", message, line)
+		}
+	}
+}
+
+func TestGetContextLoggerFallback(t *testing.T) {
+	output := &bytes.Buffer{}
+	fallback := zerolog.New(output)
+	GetContextLogger(context.Background(), &fallback).Info().Msg("fallback")
+	if line := findTestLogLine(t, output, "fallback"); line["traceId"] != nil {
+		t.Fatalf("日志为 %v", line)
+	}
+
+	// 没有日志时带上 trace ID 。
+	tracing, _ := newTestTracing(t)
+	ctx, span := tracing.StartSpan(context.Background(), "request")
+	defer span.End()
+	GetContextLogger(ctx, &fallback).Info().Msg("trace")
+	if line := findTestLogLine(t, output, "trace"); line["traceId"] != span.SpanContext().TraceID().String() {
+		t.Fatalf("日志为 %v", line)
+	}
+}
+
+func TestTaskActionLogger(t *testing.T) {
+	tracing, _ := newTestTracing(t)
+	queue := newTestTaskQueue(t, TaskQueueDi{Tracing: tracing})
+	output := &bytes.Buffer{}
+	logger := zerolog.New(output)
+	queue.Logger = &logger
+	traceId := make(chan string, 1)
+	queue.RegisterProcess("echo", func(action *TaskAction) (TaskResultMessage, error) {
+		action.Logger().Info().Msg("process")
+		traceId <- GetTraceID(action.Context())
+		return nil, nil
+	})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	id := pushTestTask(t, queue, "echo")
+	waitTaskStatus(t, queue, id, TASK_STATUS_OK)
+	stopTestTaskQueue(t, queue)
+
+	line := findTestLogLine(t, output, "process")
+	if line["taskId"] != id || line["taskName"] != "echo" || line["traceId"] != <-traceId {
+		t.Fatalf("任务日志为 %v", line)
+	}
+}
+
+func TestResetContextReqID(t *testing.T) {
+	logger := zerolog.Nop()
+	responseHeader := "X-Req-ID"
+	count := 0
+	router := NewRouter(NewRouterDi{
+		Logger: &logger,
+		Conf: &HttpServerConf{
+			ReqIDHeaders:        []string{"X-Request-ID", "X-Correlation-ID"},
+			ReqIDResponseHeader: &responseHeader,
+		},
+		ReqIDGenerator: func() string {
+			count++
+			return fmt.Sprintf("gen-%d", count)
+		},
+	})
+	router.GET("/id", func(ctx HttpContext) error {
+		return ctx.Resp(ctx.GetReqID())
+	})
+	router.GET("/error", func(ctx HttpContext) error {
+		return ctx.RespBadF("出错")
+	})
+	do := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		router.GetHandler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"信任的请求头", map[string]string{"X-Request-ID": "up-1"}, "up-1"},
+		{"依次取请求头", map[string]string{"X-Request-ID": "bad id", "X-Correlation-ID": "up-2"}, "up-2"},
+		{"不可见字符", map[string]string{"X-Request-ID": "up\x01"}, "gen-1"},
+		{"超过 128 个字符", map[string]string{"X-Request-ID": strings.Repeat("a", 129)}, "gen-2"},
+		{"不信任的请求头", map[string]string{"X-Other-ID": "up-3"}, "gen-3"},
+	}
+	for _, test := range tests {
+		rec := do("/id", test.headers)
+		body := map[string]any{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body["data"] != test.want || rec.Header().Get(responseHeader) != test.want {
+			t.Errorf("%s: 请求 ID 为 %v ，响应头为 %s", test.name, body["data"], rec.Header().Get(responseHeader))
+		}
+	}
+	if id := strings.Repeat("a", 128); do("/id", map[string]string{"X-Request-ID": id}).Header().Get(responseHeader) != id {
+		t.Errorf("128 个字符的请求 ID 被替换")
+	}
+
+	// 错误响应带上请求 ID 。
+	rec := do("/error", map[string]string{"X-Request-ID": "up-4"})
+	result := &ApiError{}
+	if err := json.Unmarshal(rec.Body.Bytes(), result); err != nil {
+		t.Fatal(err)
+	}
+	if result.ReqID != "up-4" || rec.Header().Get(responseHeader) != "up-4" {
+		t.Fatalf("错误为 %s", rec.Body)
+	}
+}
+
+func TestGetReqIDGenerator(t *testing.T) {
+	for _, name := range []string{"uuid", "uuidv7"} {
+		generate, err := GetReqIDGenerator(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := uuid.Parse(generate())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name == "uuidv7" && id.Version() != 7 {
+			t.Errorf("版本为 %d", id.Version())
+		}
+	}
+	if _, err := GetReqIDGenerator("ulid"); err == nil {
+		t.Fatal("不支持的生成方式没有报错")
+	}
+
+	// 配置的生成方式无效时使用 uuid 。
+	logger := zerolog.Nop()
+	name := "ulid"
+	router := NewRouter(NewRouterDi{Logger: &logger, Conf: &HttpServerConf{ReqIDGenerator: &name}})
+	router.GET("/id", func(ctx HttpContext) error {
+		return ctx.RespOk()
+	})
+	rec := httptest.NewRecorder()
+	router.GetHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/id", nil))
+	if _, err := uuid.Parse(rec.Header().Get("X-Request-ID")); err != nil {
+		t.Fatalf("请求 ID 为 %s", rec.Header().Get("X-Request-ID"))
+	}
+}
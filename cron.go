@@ -0,0 +1,212 @@
+package cjungo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CronSchedule interface {
+	// 返回 t 之后的下一次执行时间，没有时返回零值。
+	Next(t time.Time) time.Time
+}
+
+// 标准 5 段 cron 表达式：分 时 日 月 周，
+// 支持 * , - / 、月和周的英文缩写，以及 @hourly、@daily、@every 1h 等。
+type CronSpec struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	isStar uint8 // 日、周是否为 *
+}
+
+type CronEvery struct {
+	Duration time.Duration
+}
+
+func (every *CronEvery) Next(t time.Time) time.Time {
+	return t.Add(every.Duration).Truncate(time.Second)
+}
+
+const (
+	cronDomStar uint8 = 1 << iota
+	cronDowStar
+)
+
+type cronBounds struct {
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	cronMinuteBounds = cronBounds{0, 59, nil}
+	cronHourBounds   = cronBounds{0, 23, nil}
+	cronDomBounds    = cronBounds{1, 31, nil}
+	cronMonthBounds  = cronBounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDowBounds = cronBounds{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+func ParseCron(expr string) (CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(expr[len("@every "):]))
+		if err != nil {
+			return nil, fmt.Errorf("cron 表达式 %s 有误: %v", expr, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("cron 表达式 %s 间隔不能小于 1s", expr)
+		}
+		return &CronEvery{Duration: d}, nil
+	}
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式 %s 应为 5 段", expr)
+	}
+	spec := &CronSpec{}
+	var err error
+	if spec.minute, err = parseCronField(fields[0], cronMinuteBounds); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %s 分钟有误: %v", expr, err)
+	}
+	if spec.hour, err = parseCronField(fields[1], cronHourBounds); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %s 小时有误: %v", expr, err)
+	}
+	if spec.dom, err = parseCronField(fields[2], cronDomBounds); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %s 日有误: %v", expr, err)
+	}
+	if spec.month, err = parseCronField(fields[3], cronMonthBounds); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %s 月有误: %v", expr, err)
+	}
+	if spec.dow, err = parseCronField(fields[4], cronDowBounds); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %s 周有误: %v", expr, err)
+	}
+	// 7 也表示周日
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1
+	}
+	if fields[2] == "*" || fields[2] == "?" {
+		spec.isStar |= cronDomStar
+	}
+	if fields[4] == "*" || fields[4] == "?" {
+		spec.isStar |= cronDowStar
+	}
+	return spec, nil
+}
+
+func parseCronField(field string, bounds cronBounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			v, err := strconv.Atoi(stepPart)
+			if err != nil || v <= 0 {
+				return 0, fmt.Errorf("步长 %s 无效", stepPart)
+			}
+			step = v
+		}
+
+		var start, end int
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			start, end = bounds.min, bounds.max
+		case strings.Contains(rangePart, "-"):
+			low, high, _ := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = parseCronValue(low, bounds); err != nil {
+				return 0, err
+			}
+			if end, err = parseCronValue(high, bounds); err != nil {
+				return 0, err
+			}
+		default:
+			v, err := parseCronValue(rangePart, bounds)
+			if err != nil {
+				return 0, err
+			}
+			start, end = v, v
+			if hasStep {
+				end = bounds.max
+			}
+		}
+		if start > end {
+			return 0, fmt.Errorf("范围 %s 无效", rangePart)
+		}
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(text string, bounds cronBounds) (int, error) {
+	if v, ok := bounds.names[strings.ToLower(text)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("值 %s 无效", text)
+	}
+	if v < bounds.min || v > bounds.max {
+		return 0, fmt.Errorf("值 %d 超出范围 %d-%d", v, bounds.min, bounds.max)
+	}
+	return v, nil
+}
+
+func (spec *CronSpec) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if spec.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !spec.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if spec.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if spec.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// 日和周都有限定时，满足其一即可。
+func (spec *CronSpec) matchDay(t time.Time) bool {
+	domMatch := spec.dom&(1<<uint(t.Day())) != 0
+	dowMatch := spec.dow&(1<<uint(t.Weekday())) != 0
+	if spec.isStar&(cronDomStar|cronDowStar) != 0 {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
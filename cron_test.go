@@ -0,0 +1,70 @@
+package cjungo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronNext(t *testing.T) {
+	at := func(text string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04:05", text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	cases := []struct {
+		expr string
+		from string
+		next string // 为空时没有下一次
+	}{
+		{"*/15 * * * *", "2024-01-01 10:07:00", "2024-01-01 10:15:00"},
+		{"5-10/2 * * * *", "2024-01-01 10:05:00", "2024-01-01 10:07:00"},
+		{"0 9 * * mon-fri", "2024-01-06 10:00:00", "2024-01-08 09:00:00"},
+		{"0 0 1 * *", "2024-01-15 08:00:00", "2024-02-01 00:00:00"},
+		{"0 12 * jan,jul *", "2024-02-01 00:00:00", "2024-07-01 12:00:00"},
+		{"30 2 29 2 *", "2024-03-01 00:00:00", "2028-02-29 02:30:00"},
+		// 日和周都有限定时满足其一即可
+		{"0 0 13 * fri", "2024-01-01 00:00:00", "2024-01-05 00:00:00"},
+		{"0 0 * * 7", "2024-01-01 00:00:00", "2024-01-07 00:00:00"},
+		{"@hourly", "2024-01-01 10:30:00", "2024-01-01 11:00:00"},
+		{"@daily", "2024-01-01 10:30:00", "2024-01-02 00:00:00"},
+		{"@every 90m", "2024-01-01 10:00:30", "2024-01-01 11:30:30"},
+		{"0 0 30 2 *", "2024-01-01 00:00:00", ""},
+	}
+	for _, c := range cases {
+		schedule, err := ParseCron(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		next := schedule.Next(at(c.from))
+		if len(c.next) == 0 {
+			if !next.IsZero() {
+				t.Errorf("%s 从 %s 起不应有下一次，实际为 %v", c.expr, c.from, next)
+			}
+			continue
+		}
+		if want := at(c.next); !next.Equal(want) {
+			t.Errorf("%s 从 %s 起下一次为 %v，应为 %v", c.expr, c.from, next, want)
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * foo *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"@every 500ms",
+		"@every x",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%s 应解析失败", expr)
+		}
+	}
+}
@@ -2,16 +2,24 @@ package db
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/cjungo/cjungo"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
 	glog "gorm.io/gorm/logger"
 )
 
 type DbLogger struct {
 	sign    string
 	subject *zerolog.Logger
+	metrics *cjungo.Metrics
+	tracing *cjungo.Tracing
 }
 
 func (logger *DbLogger) LogMode(level glog.LogLevel) glog.Interface {
@@ -32,7 +40,8 @@ func (logger *DbLogger) Error(ctx context.Context, f string, a ...any) {
 }
 func (logger *DbLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
 	sql, rowsAffected := fc()
-	logger.subject.Info().
+	recordDbQuery(ctx, logger.metrics, logger.tracing, logger.sign, sql, rowsAffected, begin, err)
+	cjungo.GetContextLogger(ctx, logger.subject).Info().
 		Time("begin", begin).
 		Err(err).
 		Str("sql", sql).
@@ -43,6 +52,8 @@ func (logger *DbLogger) Trace(ctx context.Context, begin time.Time, fc func() (s
 type DbSilentLogger struct {
 	sign    string
 	subject *zerolog.Logger
+	metrics *cjungo.Metrics
+	tracing *cjungo.Tracing
 }
 
 func (logger *DbSilentLogger) LogMode(level glog.LogLevel) glog.Interface {
@@ -59,7 +70,9 @@ func (logger *DbSilentLogger) Error(ctx context.Context, f string, a ...any) {
 }
 func (logger *DbSilentLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
 	sql, rowsAffected := fc()
-	logger.subject.Info().
+	// 静默模式下 span 也只记录 SQL 开头。
+	recordDbQuery(ctx, logger.metrics, logger.tracing, logger.sign, cjungo.LimitStr(sql, 20), rowsAffected, begin, err)
+	cjungo.GetContextLogger(ctx, logger.subject).Info().
 		Time("begin", begin).
 		Str("mode", "Silent").
 		Err(err).
@@ -67,3 +80,28 @@ func (logger *DbSilentLogger) Trace(ctx context.Context, begin time.Time, fc fun
 		Int64("rowsAffected", rowsAffected).
 		Msg(logger.sign)
 }
+
+// 记录查询耗时和 span ，没有找到记录不算错误。
+// span 的父级来自 db.WithContext 传入的上下文。
+func recordDbQuery(ctx context.Context, metrics *cjungo.Metrics, tracing *cjungo.Tracing, sign string, sql string, rowsAffected int64, begin time.Time, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	system := strings.ToLower(strings.Trim(sign, "[]"))
+	metrics.ObserveDbQuery(system, sql, time.Since(begin), err)
+
+	_, span := tracing.StartSpan(ctx, system,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(begin),
+		trace.WithAttributes(
+			attribute.String("db.system", system),
+			attribute.String("db.statement", sql),
+			attribute.Int64("db.rows_affected", rowsAffected),
+		),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/cjungo/cjungo"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDbQuerySpanParent(t *testing.T) {
+	logger := zerolog.Nop()
+	exporter := tracetest.NewInMemoryExporter()
+	tracing, err := cjungo.NewTracing(cjungo.TracingDi{Exporter: exporter, Logger: &logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tracing.Stop(context.Background())
+	sqlite, err := NewSqliteHandleWithDi(func(*Sqlite) error { return nil })(SqliteDi{
+		Conf:    &SqliteConf{Path: filepath.Join(t.TempDir(), "test.db")},
+		Logger:  &logger,
+		Tracing: tracing,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 父级来自 WithContext 传入的上下文。
+	ctx, parent := tracing.StartSpan(context.Background(), "request")
+	if err := sqlite.WithContext(ctx).Exec("SELECT 1").Error; err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	for _, span := range exporter.GetSpans() {
+		if span.Name != "sqlite" {
+			continue
+		}
+		if span.SpanKind != trace.SpanKindClient || span.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Fatalf("span 为 %+v", span)
+		}
+		return
+	}
+	t.Fatalf("没有数据库 span: %+v", exporter.GetSpans())
+}
+
+func TestDbLoggerContextLogger(t *testing.T) {
+	logger := zerolog.Nop()
+	sqlite, err := NewSqliteHandleWithDi(func(*Sqlite) error { return nil })(SqliteDi{
+		Conf:   &SqliteConf{Path: filepath.Join(t.TempDir(), "test.db")},
+		Logger: &logger,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 请求日志放在 context.Context 中，查询日志带上请求的字段。
+	output := &bytes.Buffer{}
+	request := zerolog.New(output).With().Str("reqId", "r1").Str("subject", "alice").Logger()
+	if err := sqlite.WithContext(request.WithContext(context.Background())).Exec("SELECT 1").Error; err != nil {
+		t.Fatal(err)
+	}
+	line := map[string]any{}
+	if err := json.Unmarshal(bytes.TrimSpace(output.Bytes()), &line); err != nil {
+		t.Fatalf("日志为 %s", output)
+	}
+	if line["reqId"] != "r1" || line["subject"] != "alice" || line["sql"] != "SELECT 1" {
+		t.Fatalf("日志为 %v", line)
+	}
+}
@@ -1,23 +1,25 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
 	"github.com/cjungo/cjungo"
 	"github.com/rs/zerolog"
+	"go.uber.org/dig"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/plugin/dbresolver"
 )
 
 type MySqlConf struct {
-	Host string
-	Port uint16
-	User string
-	Pass string
-	Name string
+	Host string `env:"CJUNGO_MYSQL_HOST" required:"true"`
+	Port uint16 `env:"CJUNGO_MYSQL_PORT" default:"3306" min:"1"`
+	User string `env:"CJUNGO_MYSQL_USER" required:"true"`
+	Pass string `env:"CJUNGO_MYSQL_PASS" required:"true" secret:"true"`
+	Name string `env:"CJUNGO_MYSQL_NAME" required:"true"`
 }
 
 type MySql struct {
@@ -34,29 +36,63 @@ func (mysql *MySql) TransactionSilent(fc func(*gorm.DB) error, opts ...*sql.TxOp
 			Logger: &DbSilentLogger{
 				sign:    logger.sign,
 				subject: logger.subject,
+				metrics: logger.metrics,
+				tracing: logger.tracing,
 			},
 		})
 		return fc(session)
 	}, opts...)
 }
 
+// 健康检查，ping 数据库。
+func (mysql *MySql) CheckHealth(ctx context.Context) error {
+	return pingDb(ctx, mysql.DB)
+}
+
+func pingDb(ctx context.Context, db *gorm.DB) error {
+	sqlDb, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.PingContext(ctx)
+}
+
+type MySqlDi struct {
+	dig.In
+	Conf    *MySqlConf
+	Logger  *zerolog.Logger
+	Metrics *cjungo.Metrics `optional:"true"`
+	Tracing *cjungo.Tracing `optional:"true"`
+}
+
 type MySqlProvide func(*MySqlConf, *zerolog.Logger) (*MySql, error)
 
+// 不记录指标和链路追踪，需要时使用 NewMySqlHandleWithDi 。
 func NewMySqlHandle(initialize func(*MySql) error) MySqlProvide {
+	provide := NewMySqlHandleWithDi(initialize)
 	return func(conf *MySqlConf, logger *zerolog.Logger) (*MySql, error) {
-		if err := ensureMysqlDatabase(conf, logger); err != nil {
+		return provide(MySqlDi{Conf: conf, Logger: logger})
+	}
+}
+
+type MySqlDiProvide func(di MySqlDi) (*MySql, error)
+
+// 有 Metrics 、Tracing 时记录查询耗时和链路。
+func NewMySqlHandleWithDi(initialize func(*MySql) error) MySqlDiProvide {
+	return func(di MySqlDi) (*MySql, error) {
+		if err := ensureMysqlDatabase(di.Conf, di.Logger); err != nil {
 			return nil, err
 		}
 
 		dns := fmt.Sprintf(
 			"%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			conf.User,
-			conf.Pass,
-			conf.Host,
-			conf.Port,
-			conf.Name,
+			di.Conf.User,
+			di.Conf.Pass,
+			di.Conf.Host,
+			di.Conf.Port,
+			di.Conf.Name,
 		)
-		db, err := openMysql(dns, logger)
+		db, err := openMysql(dns, di.Logger, di.Metrics, di.Tracing)
 		if err != nil {
 			return nil, err
 		}
@@ -75,12 +111,14 @@ func NewMySqlHandle(initialize func(*MySql) error) MySqlProvide {
 	}
 }
 
-func openMysql(dns string, logger *zerolog.Logger) (*gorm.DB, error) {
+func openMysql(dns string, logger *zerolog.Logger, metrics *cjungo.Metrics, tracing *cjungo.Tracing) (*gorm.DB, error) {
 	return gorm.Open(mysql.Open(dns), &gorm.Config{
 		DisableForeignKeyConstraintWhenMigrating: true, // 禁止外键生成
 		Logger: &DbLogger{
 			sign:    "[MYSQL]",
 			subject: logger,
+			metrics: metrics,
+			tracing: tracing,
 		},
 	})
 }
@@ -94,7 +132,7 @@ func ensureMysqlDatabase(conf *MySqlConf, logger *zerolog.Logger) error {
 			conf.Host,
 			conf.Port,
 		)
-		db, err := openMysql(dns, logger)
+		db, err := openMysql(dns, logger, nil, nil)
 		if err != nil {
 			return err
 		}
@@ -108,36 +146,7 @@ func LoadMySqlConfFormEnv(logger *zerolog.Logger) (*MySqlConf, error) {
 
 	logger.Info().Str("action", "通过环境变量加载配置").Msg("[MYSQL]")
 
-	if err := cjungo.GetEnvStringMust("CJUNGO_MYSQL_HOST", func(v string) {
-		conf.Host = v
-	}); err != nil {
-		return nil, err
-	}
-
-	if err := cjungo.GetEnvInt("CJUNGO_MYSQL_PORT", func(v uint16) {
-		conf.Port = v
-	}); err != nil {
-		return nil, err
-	} else {
-		conf.Port = 3306
-		logger.Info().Str("action", "使用默认端口").Msg("[MYSQL]")
-	}
-
-	if err := cjungo.GetEnvStringMust("CJUNGO_MYSQL_USER", func(v string) {
-		conf.User = v
-	}); err != nil {
-		return nil, err
-	}
-
-	if err := cjungo.GetEnvStringMust("CJUNGO_MYSQL_PASS", func(v string) {
-		conf.Pass = v
-	}); err != nil {
-		return nil, err
-	}
-
-	if err := cjungo.GetEnvStringMust("CJUNGO_MYSQL_NAME", func(v string) {
-		conf.Name = v
-	}); err != nil {
+	if err := cjungo.LoadConf(conf); err != nil {
 		return nil, err
 	}
 
@@ -1,31 +1,60 @@
 package db
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 
+	"github.com/cjungo/cjungo"
 	"github.com/glebarez/sqlite"
 	"github.com/rs/zerolog"
+	"go.uber.org/dig"
 	"gorm.io/gorm"
 )
 
 type SqliteConf struct {
-	Path string
+	Path string `env:"CJUNGO_SQLITE_PATH"`
 }
 
 type Sqlite struct {
 	*gorm.DB
 }
 
+// 健康检查，ping 数据库。
+func (sqlite *Sqlite) CheckHealth(ctx context.Context) error {
+	return pingDb(ctx, sqlite.DB)
+}
+
+type SqliteDi struct {
+	dig.In
+	Conf    *SqliteConf
+	Logger  *zerolog.Logger
+	Metrics *cjungo.Metrics `optional:"true"`
+	Tracing *cjungo.Tracing `optional:"true"`
+}
+
 type SqliteProvide func(*SqliteConf, *zerolog.Logger) (*Sqlite, error)
 
+// 不记录指标和链路追踪，需要时使用 NewSqliteHandleWithDi 。
 func NewSqliteHandle(initialize func(*Sqlite) error) SqliteProvide {
+	provide := NewSqliteHandleWithDi(initialize)
 	return func(conf *SqliteConf, logger *zerolog.Logger) (*Sqlite, error) {
-		db, err := gorm.Open(sqlite.Open(conf.Path), &gorm.Config{
+		return provide(SqliteDi{Conf: conf, Logger: logger})
+	}
+}
+
+type SqliteDiProvide func(di SqliteDi) (*Sqlite, error)
+
+// 有 Metrics 、Tracing 时记录查询耗时和链路。
+func NewSqliteHandleWithDi(initialize func(*Sqlite) error) SqliteDiProvide {
+	return func(di SqliteDi) (*Sqlite, error) {
+		db, err := gorm.Open(sqlite.Open(di.Conf.Path), &gorm.Config{
 			DisableForeignKeyConstraintWhenMigrating: true, // 禁止外键生成
 			Logger: &DbLogger{
 				sign:    "[SQLITE]",
-				subject: logger,
+				subject: di.Logger,
+				metrics: di.Metrics,
+				tracing: di.Tracing,
 			},
 		})
 
@@ -42,16 +71,16 @@ func NewSqliteHandle(initialize func(*Sqlite) error) SqliteProvide {
 func LoadSqliteConfFormEnv(logger *zerolog.Logger) (*SqliteConf, error) {
 	conf := &SqliteConf{}
 	logger.Info().Str("action", "通过环境变量加载配置").Msg("[SQLITE]")
-	path := os.Getenv("CJUNGO_SQLITE_PATH")
-	if len(path) == 0 {
+	if err := cjungo.LoadConf(conf); err != nil {
+		return nil, err
+	}
+	if len(conf.Path) == 0 {
 		wd, err := os.Getwd()
 		if err != nil {
 			return nil, err
 		}
 		conf.Path = filepath.Join(wd, "cjungo.db")
 		logger.Info().Str("action", "使用默认配置").Msg("[SQLITE]")
-	} else {
-		conf.Path = path
 	}
 
 	logger.Info().Str("path", conf.Path).Str("action", "配置").Msg("[SQLITE]")
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cjungo/cjungo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TaskRecord struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	Name      string `gorm:"size:128;index"`
+	Param     string `gorm:"type:text"`
+	Trace     string `gorm:"type:text"`
+	Status    string `gorm:"size:32;index"`
+	Data      string `gorm:"type:text"`
+	Attempts  int
+	LastError string `gorm:"type:text"`
+	RunAt     *time.Time
+	Progress  float64
+	Message   string `gorm:"type:text"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (TaskRecord) TableName() string {
+	return "cjungo_task"
+}
+
+// 基于 GORM 的任务存储，可用于 Sqlite 和 MySql 。
+type TaskGormStore struct {
+	db *gorm.DB
+}
+
+func NewTaskGormStore(db *gorm.DB) (*TaskGormStore, error) {
+	if err := db.AutoMigrate(&TaskRecord{}); err != nil {
+		return nil, err
+	}
+	return &TaskGormStore{db: db}, nil
+}
+
+func (store *TaskGormStore) Create(action *cjungo.TaskAction, result *cjungo.TaskResult) error {
+	param, err := json.Marshal(action.Param)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(result.Data)
+	if err != nil {
+		return err
+	}
+	trace, err := json.Marshal(action.Trace)
+	if err != nil {
+		return err
+	}
+	return store.db.Create(&TaskRecord{
+		ID:        action.ID,
+		Name:      action.Name,
+		Param:     string(param),
+		Trace:     string(trace),
+		Status:    string(result.Status),
+		Data:      string(data),
+		RunAt:     result.RunAt,
+		CreatedAt: result.CreatedAt,
+		UpdatedAt: result.UpdatedAt,
+	}).Error
+}
+
+func (store *TaskGormStore) Update(id string, update func(result *cjungo.TaskResult)) (*cjungo.TaskResult, error) {
+	var result *cjungo.TaskResult
+	err := store.db.Transaction(func(tx *gorm.DB) error {
+		// 加行锁（SELECT ... FOR UPDATE），多个实例共用存储时不会同时取得同一任务。
+		record, err := findTaskRecord(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id)
+		if err != nil {
+			return err
+		}
+		result, err = record.toResult()
+		if err != nil {
+			return err
+		}
+		update(result)
+		if err := record.fromResult(result); err != nil {
+			return err
+		}
+		return tx.Save(record).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (store *TaskGormStore) Query(id string) (*cjungo.TaskResult, error) {
+	record, err := findTaskRecord(store.db, id)
+	if err != nil {
+		return nil, err
+	}
+	return record.toResult()
+}
+
+func (store *TaskGormStore) QueryAction(id string) (*cjungo.TaskAction, error) {
+	record, err := findTaskRecord(store.db, id)
+	if err != nil {
+		return nil, err
+	}
+	return record.toAction()
+}
+
+func (store *TaskGormStore) Delete(id string) error {
+	return store.db.Delete(&TaskRecord{}, "id = ?", id).Error
+}
+
+func (store *TaskGormStore) List(statuses ...cjungo.TaskStatus) ([]*cjungo.TaskResult, error) {
+	records, err := store.find(statuses)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*cjungo.TaskResult, len(records))
+	for i, record := range records {
+		r, err := record.toResult()
+		if err != nil {
+			return nil, err
+		}
+		result[i] = r
+	}
+	return result, nil
+}
+
+func (store *TaskGormStore) ListActions(statuses ...cjungo.TaskStatus) ([]*cjungo.TaskAction, error) {
+	records, err := store.find(statuses)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*cjungo.TaskAction, len(records))
+	for i, record := range records {
+		action, err := record.toAction()
+		if err != nil {
+			return nil, err
+		}
+		result[i] = action
+	}
+	return result, nil
+}
+
+func (store *TaskGormStore) find(statuses []cjungo.TaskStatus) ([]TaskRecord, error) {
+	records := []TaskRecord{}
+	query := store.db.Order("created_at")
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	if err := query.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (store *TaskGormStore) Purge(before time.Time) (int, error) {
+	finished := []cjungo.TaskStatus{cjungo.TASK_STATUS_DELAYED}
+	finished = append(finished, cjungo.TASK_UNFINISHED_STATUSES...)
+	result := store.db.
+		Where("updated_at < ? AND status NOT IN ?", before, finished).
+		Delete(&TaskRecord{})
+	return int(result.RowsAffected), result.Error
+}
+
+func findTaskRecord(db *gorm.DB, id string) (*TaskRecord, error) {
+	record := &TaskRecord{}
+	if err := db.Take(record, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w：%s", cjungo.ErrTaskNotFound, id)
+		}
+		return nil, err
+	}
+	return record, nil
+}
+
+func (record *TaskRecord) toAction() (*cjungo.TaskAction, error) {
+	action := &cjungo.TaskAction{
+		ID:   record.ID,
+		Name: record.Name,
+	}
+	if err := json.Unmarshal([]byte(record.Param), &action.Param); err != nil {
+		return nil, err
+	}
+	if len(record.Trace) > 0 {
+		if err := json.Unmarshal([]byte(record.Trace), &action.Trace); err != nil {
+			return nil, err
+		}
+	}
+	return action, nil
+}
+
+func (record *TaskRecord) toResult() (*cjungo.TaskResult, error) {
+	result := &cjungo.TaskResult{
+		ID:        record.ID,
+		Name:      record.Name,
+		Status:    cjungo.TaskStatus(record.Status),
+		Attempts:  record.Attempts,
+		LastError: record.LastError,
+		RunAt:     record.RunAt,
+		Progress:  record.Progress,
+		Message:   record.Message,
+		CreatedAt: record.CreatedAt,
+		UpdatedAt: record.UpdatedAt,
+	}
+	if err := json.Unmarshal([]byte(record.Data), &result.Data); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (record *TaskRecord) fromResult(result *cjungo.TaskResult) error {
+	data, err := json.Marshal(result.Data)
+	if err != nil {
+		return err
+	}
+	record.Status = string(result.Status)
+	record.Data = string(data)
+	record.Attempts = result.Attempts
+	record.LastError = result.LastError
+	record.RunAt = result.RunAt
+	record.Progress = result.Progress
+	record.Message = result.Message
+	record.UpdatedAt = result.UpdatedAt
+	return nil
+}
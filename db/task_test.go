@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cjungo/cjungo"
+	"github.com/glebarez/sqlite"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	glog "gorm.io/gorm/logger"
+)
+
+func newTestTaskStore(t *testing.T, path string) *TaskGormStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: glog.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewTaskGormStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func createTestTask(t *testing.T, store *TaskGormStore, id string, status cjungo.TaskStatus, updatedAt time.Time) {
+	t.Helper()
+	action := &cjungo.TaskAction{ID: id, Name: "echo", Param: cjungo.TaskActionParam{"n": 1.0}}
+	result := &cjungo.TaskResult{ID: id, Name: "echo", Status: status, CreatedAt: updatedAt, UpdatedAt: updatedAt}
+	if err := store.Create(action, result); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTaskGormStoreRoundTrip(t *testing.T) {
+	store := newTestTaskStore(t, filepath.Join(t.TempDir(), "task.db"))
+	now := time.Now().Truncate(time.Second)
+	action := &cjungo.TaskAction{
+		ID:    "a",
+		Name:  "echo",
+		Param: cjungo.TaskActionParam{"name": "x"},
+		Trace: map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	}
+	if err := store.Create(action, &cjungo.TaskResult{ID: "a", Name: "echo", Status: cjungo.TASK_STATUS_PENDING, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := store.Update("a", func(r *cjungo.TaskResult) {
+		r.Status = cjungo.TASK_STATUS_OK
+		r.Data = cjungo.TaskResultMessage{"count": 2.0}
+		r.Attempts = 1
+		r.Progress = 100
+		r.Message = "完成"
+	})
+	if err != nil || result.Status != cjungo.TASK_STATUS_OK {
+		t.Fatalf("更新为 %+v %v", result, err)
+	}
+
+	// 重新读取。
+	result, err = store.Query("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != cjungo.TASK_STATUS_OK || result.Data["count"] != 2.0 || result.Attempts != 1 || result.Progress != 100 || result.Message != "完成" {
+		t.Fatalf("结果为 %+v", result)
+	}
+	loaded, err := store.QueryAction("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Param["name"] != "x" || loaded.Trace["traceparent"] != action.Trace["traceparent"] {
+		t.Fatalf("任务为 %+v", loaded)
+	}
+	if list, err := store.List(cjungo.TASK_STATUS_OK); err != nil || len(list) != 1 {
+		t.Fatalf("列出为 %v %v", list, err)
+	}
+
+	if _, err := store.Update("missing", func(r *cjungo.TaskResult) {}); err == nil {
+		t.Fatal("不存在的任务应失败")
+	}
+	if err := store.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Query("a"); err == nil {
+		t.Fatal("删除后应查询不到")
+	}
+}
+
+func TestTaskGormStorePurge(t *testing.T) {
+	store := newTestTaskStore(t, filepath.Join(t.TempDir(), "task.db"))
+	old := time.Now().Add(-time.Hour)
+	createTestTask(t, store, "ok", cjungo.TASK_STATUS_OK, old)
+	createTestTask(t, store, "dead", cjungo.TASK_STATUS_DEAD, old)
+	createTestTask(t, store, "recent", cjungo.TASK_STATUS_OK, time.Now())
+	createTestTask(t, store, "pending", cjungo.TASK_STATUS_PENDING, old)
+	createTestTask(t, store, "retry", cjungo.TASK_STATUS_RETRY, old)
+	createTestTask(t, store, "delayed", cjungo.TASK_STATUS_DELAYED, old)
+
+	// 未完成和延迟的任务不清理。
+	count, err := store.Purge(time.Now().Add(-time.Minute))
+	if err != nil || count != 2 {
+		t.Fatalf("清理 %d 个 %v", count, err)
+	}
+	list, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]bool{}
+	for _, result := range list {
+		ids[result.ID] = true
+	}
+	for _, id := range []string{"recent", "pending", "retry", "delayed"} {
+		if !ids[id] {
+			t.Errorf("%s 不应被清理", id)
+		}
+	}
+}
+
+func TestTaskGormStoreRecover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "task.db")
+	store := newTestTaskStore(t, path)
+	createTestTask(t, store, "pending", cjungo.TASK_STATUS_PENDING, time.Now())
+	createTestTask(t, store, "start", cjungo.TASK_STATUS_START, time.Now())
+	createTestTask(t, store, "ok", cjungo.TASK_STATUS_OK, time.Now())
+
+	// 重新打开存储，模拟重启后恢复未完成的任务。
+	logger := zerolog.Nop()
+	queue, err := cjungo.NewTaskQueueHandle(func(*cjungo.TaskQueue) error { return nil })(cjungo.TaskQueueDi{
+		Store:  newTestTaskStore(t, path),
+		Logger: &logger,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	processed := make(chan string, 3)
+	queue.RegisterProcess("echo", func(action *cjungo.TaskAction) (cjungo.TaskResultMessage, error) {
+		processed <- action.ID
+		return cjungo.TaskResultMessage{"n": action.Param["n"]}, nil
+	})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Stop(context.Background())
+
+	seen := map[string]bool{}
+	for len(seen) < 2 {
+		select {
+		case id := <-processed:
+			seen[id] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("只恢复了 %v", seen)
+		}
+	}
+	if !seen["pending"] || !seen["start"] {
+		t.Fatalf("恢复了 %v", seen)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		result, err := queue.QueryTask("pending")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status == cjungo.TASK_STATUS_OK {
+			if result.Data["n"] != 1.0 || result.Attempts != 1 {
+				t.Fatalf("结果为 %+v", result)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("状态为 %s", result.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case id := <-processed:
+		t.Fatalf("%s 不应执行", id)
+	default:
+	}
+}
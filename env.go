@@ -2,72 +2,384 @@ package cjungo
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// 进程本身的环境变量的来源标记。
+const ENV_SOURCE_PROCESS = "<process>"
+
+var (
+	envMutex   sync.RWMutex
+	envFiles   []string
+	envSources = map[string]string{}
+	envLoaded  bool
+)
+
+// 加载 .env 文件，从当前目录向上查找到模块根目录（go.mod 所在目录）。
+// 按以下顺序叠加，后者覆盖前者，同一层里靠近当前目录的覆盖上层目录的：
+//
+//	.env < .env.<profile> < .env.local < .env.<profile>.local
+//
+// profile 取自 CJUNGO_PROFILE（进程环境变量优先，其次 .env 文件）。
+// 进程本身的环境变量不会被覆盖。值中可以用 ${OTHER} 引用其他变量。
+// 重复调用会先撤销上次加载的变量，可用于重新加载。
 func LoadEnv() error {
-	pwd, err := os.Getwd()
+	snapshot, err := readEnvSnapshot()
 	if err != nil {
 		return err
 	}
-	envPath := filepath.Join(pwd, ".env")
+	return snapshot.apply()
+}
+
+// LoadEnv 计算出的环境变量，apply 之后才写入进程。
+type envSnapshot struct {
+	env     map[string]string
+	sources map[string]string
+	files   []string
+}
 
-	if IsFileExist(envPath) {
-		if err := godotenv.Load(envPath); err != nil {
-			return err
+// 在副本上计算，不改动当前环境变量。
+func readEnvSnapshot() (*envSnapshot, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	dirs := findEnvDirs(pwd)
+
+	envMutex.RLock()
+	defer envMutex.RUnlock()
+
+	env := map[string]string{}
+	sources := map[string]string{}
+	for _, item := range os.Environ() {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
 		}
+		if source, loaded := envSources[name]; loaded && source != ENV_SOURCE_PROCESS {
+			continue // 上次加载的变量
+		}
+		env[name] = value
+		sources[name] = ENV_SOURCE_PROCESS
 	}
+	files := []string{}
 
+	load := func(filename string) error {
+		for _, dir := range dirs {
+			path := filepath.Join(dir, filename)
+			if !IsFileExist(path) {
+				continue
+			}
+			values, err := readEnvFile(path, env)
+			if err != nil {
+				return fmt.Errorf("加载 %s 失败: %v", path, err)
+			}
+			for name, value := range values {
+				if sources[name] == ENV_SOURCE_PROCESS {
+					continue
+				}
+				env[name] = value
+				sources[name] = path
+			}
+			files = append(files, path)
+		}
+		return nil
+	}
+
+	if err := load(".env"); err != nil {
+		return nil, err
+	}
+	profile := env["CJUNGO_PROFILE"]
+	if len(profile) > 0 {
+		if err := load(".env." + profile); err != nil {
+			return nil, err
+		}
+	}
+	if err := load(".env.local"); err != nil {
+		return nil, err
+	}
+	if len(profile) > 0 {
+		if err := load(".env." + profile + ".local"); err != nil {
+			return nil, err
+		}
+	}
+	return &envSnapshot{env: env, sources: sources, files: files}, nil
+}
+
+// 写入进程环境变量，撤销上次加载而这次没有的变量。
+func (snapshot *envSnapshot) apply() error {
+	envMutex.Lock()
+	defer envMutex.Unlock()
+	for name, source := range envSources {
+		if _, ok := snapshot.sources[name]; !ok && source != ENV_SOURCE_PROCESS {
+			os.Unsetenv(name)
+		}
+	}
+	for name, source := range snapshot.sources {
+		if source != ENV_SOURCE_PROCESS {
+			if err := os.Setenv(name, snapshot.env[name]); err != nil {
+				return err
+			}
+		}
+	}
+	envFiles = snapshot.files
+	envSources = snapshot.sources
+	envLoaded = true
 	return nil
 }
 
-func GetEnvInt[T int | uint16](name string, onResult func(T)) error {
-	text := os.Getenv(name)
-	if len(text) > 0 {
-		v, err := strconv.Atoi(text)
-		if err != nil {
-			return err
+// 同 lookupEnvSource ，从尚未写入的环境变量中读取。
+func (snapshot *envSnapshot) lookup(name string) (string, string, bool, error) {
+	return lookupEnvWith(name, func(name string) string {
+		return snapshot.env[name]
+	}, func(name string) string {
+		return snapshot.sources[name]
+	})
+}
+
+// 是否调用过 LoadEnv 。
+func IsEnvLoaded() bool {
+	envMutex.RLock()
+	defer envMutex.RUnlock()
+	return envLoaded
+}
+
+// 返回环境变量的来源：.env 文件路径，或 ENV_SOURCE_PROCESS ，未通过 LoadEnv 加载时返回空。
+func GetEnvSource(name string) string {
+	envMutex.RLock()
+	defer envMutex.RUnlock()
+	return envSources[name]
+}
+
+// 返回所有通过 .env 文件加载的变量及其来源文件。
+func GetEnvSources() map[string]string {
+	envMutex.RLock()
+	defer envMutex.RUnlock()
+	result := map[string]string{}
+	for name, source := range envSources {
+		if source != ENV_SOURCE_PROCESS {
+			result[name] = source
+		}
+	}
+	return result
+}
+
+// 返回 LoadEnv 按顺序加载过的文件。
+func GetEnvFiles() []string {
+	envMutex.RLock()
+	defer envMutex.RUnlock()
+	return append([]string{}, envFiles...)
+}
+
+// 从 pwd 向上直到 go.mod 所在目录，按从上到下的顺序返回；找不到 go.mod 时只用 pwd 。
+func findEnvDirs(pwd string) []string {
+	dirs := []string{}
+	for dir := pwd; ; {
+		dirs = append([]string{dir}, dirs...)
+		if IsFileExist(filepath.Join(dir, "go.mod")) {
+			return dirs
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return []string{pwd}
+		}
+		dir = parent
+	}
+}
+
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// godotenv 只在同一个文件内展开 ${OTHER} ，
+// 这里把当前已有的变量写在文件内容之前，使其可以引用进程环境变量和之前加载的文件。
+func readEnvFile(path string, env map[string]string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	names, err := godotenv.Unmarshal(string(content))
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]string{}
+	for name, value := range env {
+		if envNameRegex.MatchString(name) {
+			known[name] = value
 		}
-		r := T(v)
-		onResult(r)
 	}
+	prefix, err := godotenv.Marshal(known)
+	if err != nil {
+		return nil, err
+	}
+	values, err := godotenv.Unmarshal(prefix + "\n" + string(content))
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]string{}
+	for name := range names {
+		result[name] = values[name]
+	}
+	return result, nil
+}
+
+// 读取环境变量，为空时读取 name_FILE 指定的文件内容（如 Docker secrets），去掉末尾换行。
+func LookupEnv(name string) (string, bool, error) {
+	text, _, ok, err := lookupEnvSource(name)
+	return text, ok, err
+}
+
+// 同 LookupEnv ，另外返回值所在的文件：.env 文件或 name_FILE 指定的文件。
+func lookupEnvSource(name string) (string, string, bool, error) {
+	return lookupEnvWith(name, os.Getenv, GetEnvSource)
+}
+
+func lookupEnvWith(name string, getenv func(string) string, getSource func(string) string) (string, string, bool, error) {
+	if text := getenv(name); len(text) > 0 {
+		file := getSource(name)
+		if file == ENV_SOURCE_PROCESS {
+			file = ""
+		}
+		return text, file, true, nil
+	}
+	path := getenv(name + "_FILE")
+	if len(path) == 0 {
+		return "", "", false, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", false, fmt.Errorf("读取 %s_FILE 指定的文件失败: %v", name, err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), path, true, nil
+}
+
+// 读取环境变量并转换为 T ，支持的类型与配置加载器相同，为空时不回调。
+func GetEnv[T any](name string, onResult func(T)) error {
+	text, ok, err := LookupEnv(name)
+	if err != nil || !ok {
+		return err
+	}
+	var v T
+	if err := setConfValue(reflect.ValueOf(&v).Elem(), text); err != nil {
+		return fmt.Errorf("环境变量 %s 的值 %q 无效: %v", name, text, err)
+	}
+	onResult(v)
 	return nil
 }
 
+type EnvInt interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+type EnvFloat interface {
+	~float32 | ~float64
+}
+
+func GetEnvInt[T EnvInt](name string, onResult func(T)) error {
+	return GetEnv(name, onResult)
+}
+
+func GetEnvFloat[T EnvFloat](name string, onResult func(T)) error {
+	return GetEnv(name, onResult)
+}
+
+func GetEnvString(name string, onResult func(string)) error {
+	return GetEnv(name, onResult)
+}
+
 func GetEnvStringMust(name string, onResult func(string)) error {
-	text := os.Getenv(name)
-	if len(text) > 0 {
+	text, ok, err := LookupEnv(name)
+	if err != nil {
+		return err
+	}
+	if ok {
 		onResult(text)
 		return nil
 	}
 	return fmt.Errorf("环境变量 %s 不能为空", name)
 }
 
-func GetEnvDuration(name string, onResult func(time.Duration)) error {
-	text := os.Getenv(name)
-	if len(text) > 0 {
-		v, err := time.ParseDuration(text)
-		if err != nil {
-			return err
-		}
-		onResult(v)
+// 未设置时返回 nil 。
+func GetEnvStringOptional(name string) (*string, error) {
+	text, ok, err := LookupEnv(name)
+	if err != nil || !ok {
+		return nil, err
 	}
-	return nil
+	return &text, nil
+}
+
+// 格式：a,b,c
+func GetEnvSlice[T any](name string, onResult func([]T)) error {
+	return GetEnv(name, onResult)
+}
+
+// 格式：a=1,b=2
+func GetEnvMap[K comparable, V any](name string, onResult func(map[K]V)) error {
+	return GetEnv(name, onResult)
+}
+
+func GetEnvURL(name string, onResult func(*url.URL)) error {
+	return GetEnv(name, onResult)
+}
+
+// 格式：10MB 、512KiB 、1G
+func GetEnvByteSize(name string, onResult func(ByteSize)) error {
+	return GetEnv(name, onResult)
+}
+
+func GetEnvDuration(name string, onResult func(time.Duration)) error {
+	return GetEnv(name, onResult)
 }
 
 func GetEnvBool(name string, onResult func(bool)) error {
-	text := os.Getenv(name)
-	if len(text) > 0 {
-		v, err := strconv.ParseBool(text)
-		if err != nil {
-			return err
-		}
-		onResult(v)
+	return GetEnv(name, onResult)
+}
+
+// 字节数，配置中可以写成 10MB 、512KiB 这样的形式，
+// KB/MB/GB/TB 与 KiB/MiB/GiB/TiB 都按 1024 进位。
+type ByteSize int64
+
+var byteSizeUnits = map[string]ByteSize{
+	"":  1,
+	"B": 1,
+	"K": 1 << 10, "KB": 1 << 10, "KIB": 1 << 10,
+	"M": 1 << 20, "MB": 1 << 20, "MIB": 1 << 20,
+	"G": 1 << 30, "GB": 1 << 30, "GIB": 1 << 30,
+	"T": 1 << 40, "TB": 1 << 40, "TIB": 1 << 40,
+}
+
+func ParseByteSize(text string) (ByteSize, error) {
+	text = strings.TrimSpace(text)
+	i := strings.IndexFunc(text, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(text)
+	}
+	unit, ok := byteSizeUnits[strings.ToUpper(strings.TrimSpace(text[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("无效的单位: %s", text[i:])
+	}
+	n, err := strconv.ParseFloat(text[:i], 64)
+	if err != nil {
+		return 0, err
+	}
+	return ByteSize(n * float64(unit)), nil
+}
+
+func (size *ByteSize) UnmarshalText(text []byte) error {
+	v, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
 	}
+	*size = v
 	return nil
 }
@@ -0,0 +1,282 @@
+package cjungo
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 在 dir 下执行 LoadEnv ，结束后撤销加载的变量并恢复工作目录。
+func loadTestEnv(t *testing.T, dir string) {
+	t.Helper()
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		// 在没有 .env 文件的模块中重新加载，撤销上次加载的变量。
+		empty := t.TempDir()
+		os.WriteFile(filepath.Join(empty, "go.mod"), []byte("module empty\n"), 0o644)
+		os.Chdir(empty)
+		LoadEnv()
+		os.Chdir(pwd)
+	})
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadEnv(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 测试期间移除进程环境变量，结束后恢复。
+func unsetTestEnv(t *testing.T, name string) {
+	t.Helper()
+	if value, ok := os.LookupEnv(name); ok {
+		os.Unsetenv(name)
+		t.Cleanup(func() { os.Setenv(name, value) })
+	}
+}
+
+func writeTestEnvFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadEnvProfileLayering(t *testing.T) {
+	unsetTestEnv(t, "CJUNGO_PROFILE")
+	t.Setenv("CJUNGO_TEST_ENV_P", "process")
+
+	outer := t.TempDir()
+	root := filepath.Join(outer, "mod")
+	sub := filepath.Join(root, "sub")
+	writeTestEnvFiles(t, map[string]string{
+		filepath.Join(outer, ".env"):          "CJUNGO_TEST_ENV_OUTER=1\n",
+		filepath.Join(root, "go.mod"):         "module test\n",
+		filepath.Join(root, ".env"):           "CJUNGO_PROFILE=dev\nCJUNGO_TEST_ENV_A=root\nCJUNGO_TEST_ENV_B=root\nCJUNGO_TEST_ENV_BASE=/srv\n",
+		filepath.Join(sub, ".env"):            "CJUNGO_TEST_ENV_A=sub\nCJUNGO_TEST_ENV_P=file\n",
+		filepath.Join(sub, ".env.dev"):        "CJUNGO_TEST_ENV_B=dev\nCJUNGO_TEST_ENV_C=${CJUNGO_TEST_ENV_BASE}/data\nCJUNGO_TEST_ENV_D=dev\n",
+		filepath.Join(sub, ".env.local"):      "CJUNGO_TEST_ENV_D=local\nCJUNGO_TEST_ENV_E=${CJUNGO_TEST_ENV_P}-local\n",
+		filepath.Join(sub, ".env.dev.local"):  "CJUNGO_TEST_ENV_D=devlocal\n",
+		filepath.Join(sub, ".env.test.local"): "CJUNGO_TEST_ENV_D=test\n",
+	})
+	loadTestEnv(t, sub)
+
+	for name, want := range map[string]string{
+		"CJUNGO_TEST_ENV_A": "sub",       // 靠近当前目录的覆盖上层目录
+		"CJUNGO_TEST_ENV_B": "dev",       // profile 覆盖 .env
+		"CJUNGO_TEST_ENV_C": "/srv/data", // 引用之前文件的变量
+		"CJUNGO_TEST_ENV_D": "devlocal",  // .env.<profile>.local 最后加载
+		"CJUNGO_TEST_ENV_E": "process-local",
+		"CJUNGO_TEST_ENV_P": "process", // 进程环境变量不被覆盖
+	} {
+		if got := os.Getenv(name); got != want {
+			t.Errorf("%s 为 %q，应为 %q", name, got, want)
+		}
+	}
+	// 不读取 go.mod 所在目录之外的文件。
+	if _, ok := os.LookupEnv("CJUNGO_TEST_ENV_OUTER"); ok {
+		t.Error("不应加载模块根目录之外的 .env")
+	}
+
+	if source := GetEnvSource("CJUNGO_TEST_ENV_A"); source != filepath.Join(sub, ".env") {
+		t.Errorf("来源为 %s", source)
+	}
+	if source := GetEnvSource("CJUNGO_TEST_ENV_P"); source != ENV_SOURCE_PROCESS {
+		t.Errorf("来源为 %s", source)
+	}
+	want := []string{
+		filepath.Join(root, ".env"),
+		filepath.Join(sub, ".env"),
+		filepath.Join(sub, ".env.dev"),
+		filepath.Join(sub, ".env.local"),
+		filepath.Join(sub, ".env.dev.local"),
+	}
+	if got := GetEnvFiles(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("加载的文件 %v，应为 %v", got, want)
+	}
+
+	// 重新加载时撤销文件中已删除的变量。
+	writeTestEnvFiles(t, map[string]string{
+		filepath.Join(sub, ".env.local"): "CJUNGO_TEST_ENV_D=local\n",
+	})
+	if err := LoadEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := os.LookupEnv("CJUNGO_TEST_ENV_E"); ok {
+		t.Error("重新加载后应撤销已删除的变量")
+	}
+	if got := os.Getenv("CJUNGO_TEST_ENV_A"); got != "sub" {
+		t.Errorf("重新加载后为 %q", got)
+	}
+}
+
+func TestLoadEnvInvalidFileKeepEnv(t *testing.T) {
+	root := t.TempDir()
+	writeTestEnvFiles(t, map[string]string{
+		filepath.Join(root, "go.mod"): "module test\n",
+		filepath.Join(root, ".env"):   "CJUNGO_TEST_ENV_KEEP=1\n",
+	})
+	loadTestEnv(t, root)
+
+	// 出错时不改动当前环境变量。
+	writeTestEnvFiles(t, map[string]string{
+		filepath.Join(root, ".env"): "CJUNGO_TEST_ENV_KEEP='unterminated\n",
+	})
+	if err := LoadEnv(); err == nil {
+		t.Fatal("应加载失败")
+	}
+	if got := os.Getenv("CJUNGO_TEST_ENV_KEEP"); got != "1" {
+		t.Fatalf("失败后为 %q", got)
+	}
+}
+
+func TestFindEnvDirs(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	writeTestEnvFiles(t, map[string]string{
+		filepath.Join(root, "go.mod"): "module test\n",
+		filepath.Join(sub, "x"):       "",
+	})
+	dirs := findEnvDirs(sub)
+	want := []string{root, filepath.Join(root, "a"), sub}
+	if strings.Join(dirs, "|") != strings.Join(want, "|") {
+		t.Fatalf("目录为 %v，应为 %v", dirs, want)
+	}
+
+	// 找不到 go.mod 时只用当前目录。
+	alone := t.TempDir()
+	if dirs := findEnvDirs(alone); len(dirs) != 1 || dirs[0] != alone {
+		t.Fatalf("目录为 %v", dirs)
+	}
+}
+
+func TestLookupEnvFile(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret")
+	writeTestEnvFiles(t, map[string]string{secret: "p@ss\r\n"})
+	unsetTestEnv(t, "CJUNGO_TEST_SECRET")
+	t.Setenv("CJUNGO_TEST_SECRET_FILE", secret)
+
+	// 为空时读取 _FILE 指定的文件，去掉末尾换行。
+	text, ok, err := LookupEnv("CJUNGO_TEST_SECRET")
+	if err != nil || !ok || text != "p@ss" {
+		t.Fatalf("读取为 %q %v %v", text, ok, err)
+	}
+	if _, source, _, _ := lookupEnvSource("CJUNGO_TEST_SECRET"); source != secret {
+		t.Errorf("来源为 %s", source)
+	}
+
+	// 环境变量优先。
+	t.Setenv("CJUNGO_TEST_SECRET", "direct")
+	if text, _, _ := LookupEnv("CJUNGO_TEST_SECRET"); text != "direct" {
+		t.Errorf("读取为 %q", text)
+	}
+
+	// 文件不存在时报错。
+	t.Setenv("CJUNGO_TEST_MISSING_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, _, err := LookupEnv("CJUNGO_TEST_MISSING"); err == nil {
+		t.Error("文件不存在应失败")
+	}
+	if _, ok, err := LookupEnv("CJUNGO_TEST_NOT_SET"); ok || err != nil {
+		t.Errorf("未设置时为 %v %v", ok, err)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for text, want := range map[string]ByteSize{
+		"512":      512,
+		"1B":       1,
+		"10KB":     10 << 10,
+		"512KiB":   512 << 10,
+		"1.5M":     3 << 19,
+		" 2 gib ":  2 << 30,
+		"1TB":      1 << 40,
+		"0":        0,
+		"100 MiB ": 100 << 20,
+	} {
+		got, err := ParseByteSize(text)
+		if err != nil || got != want {
+			t.Errorf("%q 解析为 %d %v，应为 %d", text, got, err, want)
+		}
+	}
+	for _, text := range []string{"", "10XB", "MB", "1.2.3K"} {
+		if _, err := ParseByteSize(text); err == nil {
+			t.Errorf("%q 应解析失败", text)
+		}
+	}
+
+	var size ByteSize
+	if err := size.UnmarshalText([]byte("4KB")); err != nil || size != 4096 {
+		t.Errorf("UnmarshalText 为 %d %v", size, err)
+	}
+}
+
+func TestGetEnvHelpers(t *testing.T) {
+	t.Setenv("CJUNGO_TEST_INT", "42")
+	t.Setenv("CJUNGO_TEST_FLOAT", "0.25")
+	t.Setenv("CJUNGO_TEST_SLICE", "a,b")
+	t.Setenv("CJUNGO_TEST_MAP", "a=1,b=2")
+	t.Setenv("CJUNGO_TEST_SIZE", "2MB")
+	t.Setenv("CJUNGO_TEST_DURATION", "1m30s")
+	t.Setenv("CJUNGO_TEST_BOOL", "true")
+	t.Setenv("CJUNGO_TEST_URL", "https://example.com/a")
+	t.Setenv("CJUNGO_TEST_BAD", "abc")
+
+	var i int8
+	var f float32
+	var slice []string
+	var m map[string]int
+	var size ByteSize
+	var d time.Duration
+	var b bool
+	var u *url.URL
+	for _, err := range []error{
+		GetEnvInt("CJUNGO_TEST_INT", func(v int8) { i = v }),
+		GetEnvFloat("CJUNGO_TEST_FLOAT", func(v float32) { f = v }),
+		GetEnvSlice("CJUNGO_TEST_SLICE", func(v []string) { slice = v }),
+		GetEnvMap("CJUNGO_TEST_MAP", func(v map[string]int) { m = v }),
+		GetEnvByteSize("CJUNGO_TEST_SIZE", func(v ByteSize) { size = v }),
+		GetEnvDuration("CJUNGO_TEST_DURATION", func(v time.Duration) { d = v }),
+		GetEnvBool("CJUNGO_TEST_BOOL", func(v bool) { b = v }),
+		GetEnvURL("CJUNGO_TEST_URL", func(v *url.URL) { u = v }),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if i != 42 || f != 0.25 || strings.Join(slice, "|") != "a|b" || m["b"] != 2 {
+		t.Errorf("读取为 %d %v %v %v", i, f, slice, m)
+	}
+	if size != 2<<20 || d != 90*time.Second || !b || u.Host != "example.com" {
+		t.Errorf("读取为 %d %v %v %v", size, d, b, u)
+	}
+
+	// 未设置时不回调。
+	called := false
+	if err := GetEnvInt("CJUNGO_TEST_NOT_SET", func(v int) { called = true }); err != nil || called {
+		t.Errorf("未设置时为 %v %v", called, err)
+	}
+	if err := GetEnvInt("CJUNGO_TEST_BAD", func(v int) {}); err == nil || !strings.Contains(err.Error(), "CJUNGO_TEST_BAD") {
+		t.Errorf("错误为 %v", err)
+	}
+
+	if err := GetEnvStringMust("CJUNGO_TEST_NOT_SET", func(v string) {}); err == nil {
+		t.Error("未设置应失败")
+	}
+	if text, err := GetEnvStringOptional("CJUNGO_TEST_NOT_SET"); text != nil || err != nil {
+		t.Errorf("未设置时为 %v %v", text, err)
+	}
+	if text, err := GetEnvStringOptional("CJUNGO_TEST_BAD"); text == nil || *text != "abc" || err != nil {
+		t.Errorf("读取为 %v %v", text, err)
+	}
+}
@@ -2,14 +2,31 @@ package cjungo
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/labstack/echo/v4"
 )
 
 type ApiError struct {
-	Code     int   `json:"code"`
-	Message  any   `json:"message"`
-	HttpCode int   `json:"-"`
-	Reason   error `json:"-"`
+	Code     int           `json:"code"`
+	Name     string        `json:"name,omitempty"` // 错误码名称，如 NOT_FOUND
+	Message  any           `json:"message"`
+	I18nKey  string        `json:"i18nKey,omitempty"` // 前端按此翻译 message
+	Details  []ErrorDetail `json:"details,omitempty"` // 字段级错误，用于参数校验
+	HttpCode int           `json:"-"`
+	Reason   error         `json:"-"`
+	ReqID    string        `json:"reqId,omitempty"` // 由 HTTPErrorHandler 填写
+}
+
+// 字段级错误。
+type ErrorDetail struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	I18nKey string `json:"i18nKey,omitempty"`
 }
 
 func (err *ApiError) Error() string {
@@ -19,3 +36,202 @@ func (err *ApiError) Error() string {
 		return string(result)
 	}
 }
+
+// 使 errors.Is 、errors.As 可以检查 Reason 。
+func (err *ApiError) Unwrap() error {
+	return err.Reason
+}
+
+// 返回替换了默认消息的副本，不修改原错误。
+func (err *ApiError) WithMessage(message any) *ApiError {
+	result := *err
+	result.Message = message
+	return &result
+}
+
+// 返回追加了字段级错误的副本，不修改原错误。
+func (err *ApiError) WithDetails(details ...ErrorDetail) *ApiError {
+	result := *err
+	result.Details = append(append([]ErrorDetail{}, err.Details...), details...)
+	return &result
+}
+
+// 错误码定义。
+type ErrorCode struct {
+	Code     int
+	Name     string
+	HttpCode int
+	Message  string // 默认消息
+	I18nKey  string
+}
+
+const (
+	ERROR_CODE_UNKNOWN             = -1
+	ERROR_CODE_BAD_REQUEST         = 40000
+	ERROR_CODE_VALIDATION          = 40001
+	ERROR_CODE_UNAUTHORIZED        = 40100
+	ERROR_CODE_FORBIDDEN           = 40300
+	ERROR_CODE_NOT_FOUND           = 40400
+	ERROR_CODE_CONFLICT            = 40900
+	ERROR_CODE_TOO_MANY_REQUESTS   = 42900
+	ERROR_CODE_INTERNAL            = 50000
+	ERROR_CODE_SERVICE_UNAVAILABLE = 50300
+)
+
+var (
+	errorCodes = map[int]*ErrorCode{
+		ERROR_CODE_UNKNOWN:             {ERROR_CODE_UNKNOWN, "UNKNOWN", http.StatusInternalServerError, "未知错误", "error.unknown"},
+		ERROR_CODE_BAD_REQUEST:         {ERROR_CODE_BAD_REQUEST, "BAD_REQUEST", http.StatusBadRequest, "请求错误", "error.bad_request"},
+		ERROR_CODE_VALIDATION:          {ERROR_CODE_VALIDATION, "VALIDATION", http.StatusBadRequest, "参数校验失败", "error.validation"},
+		ERROR_CODE_UNAUTHORIZED:        {ERROR_CODE_UNAUTHORIZED, "UNAUTHORIZED", http.StatusUnauthorized, "未登录或登录已过期", "error.unauthorized"},
+		ERROR_CODE_FORBIDDEN:           {ERROR_CODE_FORBIDDEN, "FORBIDDEN", http.StatusForbidden, "没有权限", "error.forbidden"},
+		ERROR_CODE_NOT_FOUND:           {ERROR_CODE_NOT_FOUND, "NOT_FOUND", http.StatusNotFound, "资源不存在", "error.not_found"},
+		ERROR_CODE_CONFLICT:            {ERROR_CODE_CONFLICT, "CONFLICT", http.StatusConflict, "资源冲突", "error.conflict"},
+		ERROR_CODE_TOO_MANY_REQUESTS:   {ERROR_CODE_TOO_MANY_REQUESTS, "TOO_MANY_REQUESTS", http.StatusTooManyRequests, "请求过于频繁", "error.too_many_requests"},
+		ERROR_CODE_INTERNAL:            {ERROR_CODE_INTERNAL, "INTERNAL", http.StatusInternalServerError, "服务器错误", "error.internal"},
+		ERROR_CODE_SERVICE_UNAVAILABLE: {ERROR_CODE_SERVICE_UNAVAILABLE, "SERVICE_UNAVAILABLE", http.StatusServiceUnavailable, "服务暂不可用", "error.service_unavailable"},
+	}
+	errorCodesMutex sync.RWMutex
+
+	// echo.HTTPError 的状态码对应的错误码。
+	httpErrorCodes = map[int]int{
+		http.StatusBadRequest:         ERROR_CODE_BAD_REQUEST,
+		http.StatusUnauthorized:       ERROR_CODE_UNAUTHORIZED,
+		http.StatusForbidden:          ERROR_CODE_FORBIDDEN,
+		http.StatusNotFound:           ERROR_CODE_NOT_FOUND,
+		http.StatusConflict:           ERROR_CODE_CONFLICT,
+		http.StatusTooManyRequests:    ERROR_CODE_TOO_MANY_REQUESTS,
+		http.StatusServiceUnavailable: ERROR_CODE_SERVICE_UNAVAILABLE,
+	}
+)
+
+// 注册业务错误码，错误码和名称不能重复。
+func RegisterErrorCode(code ErrorCode) error {
+	if len(code.Name) == 0 {
+		return fmt.Errorf("错误码 %d 缺少名称", code.Code)
+	}
+	if code.HttpCode < 400 || code.HttpCode > 599 {
+		return fmt.Errorf("错误码 %s 的 HTTP 状态码 %d 无效", code.Name, code.HttpCode)
+	}
+	errorCodesMutex.Lock()
+	defer errorCodesMutex.Unlock()
+	if _, ok := errorCodes[code.Code]; ok {
+		return fmt.Errorf("错误码 %d 已存在", code.Code)
+	}
+	for _, item := range errorCodes {
+		if item.Name == code.Name {
+			return fmt.Errorf("错误码名称 %s 已存在", code.Name)
+		}
+	}
+	errorCodes[code.Code] = &code
+	return nil
+}
+
+func GetErrorCode(code int) (ErrorCode, bool) {
+	errorCodesMutex.RLock()
+	defer errorCodesMutex.RUnlock()
+	if item, ok := errorCodes[code]; ok {
+		return *item, true
+	}
+	return ErrorCode{}, false
+}
+
+// 所有错误码，按错误码排序，可以导出给前端。
+func GetErrorCodes() []ErrorCode {
+	errorCodesMutex.RLock()
+	defer errorCodesMutex.RUnlock()
+	result := make([]ErrorCode, 0, len(errorCodes))
+	for _, item := range errorCodes {
+		result = append(result, *item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Code < result[j].Code
+	})
+	return result
+}
+
+// 按错误码生成错误，使用默认消息，reason 只记录日志不返回给前端。
+// 没有注册的错误码按 HTTP 500 处理。
+func NewApiError(code int, reason error) *ApiError {
+	item, ok := GetErrorCode(code)
+	if !ok {
+		item = ErrorCode{Code: code, HttpCode: http.StatusInternalServerError, Message: "未知错误"}
+	}
+	return &ApiError{
+		Code:     item.Code,
+		Name:     item.Name,
+		Message:  item.Message,
+		I18nKey:  item.I18nKey,
+		HttpCode: item.HttpCode,
+		Reason:   reason,
+	}
+}
+
+func ErrBadRequest(reason error) *ApiError {
+	return NewApiError(ERROR_CODE_BAD_REQUEST, reason)
+}
+
+// 参数校验失败，details 说明每个字段的错误。
+func ErrValidation(details ...ErrorDetail) *ApiError {
+	return NewApiError(ERROR_CODE_VALIDATION, nil).WithDetails(details...)
+}
+
+func ErrUnauthorized(reason error) *ApiError {
+	return NewApiError(ERROR_CODE_UNAUTHORIZED, reason)
+}
+
+func ErrForbidden(reason error) *ApiError {
+	return NewApiError(ERROR_CODE_FORBIDDEN, reason)
+}
+
+func ErrNotFound(reason error) *ApiError {
+	return NewApiError(ERROR_CODE_NOT_FOUND, reason)
+}
+
+func ErrConflict(reason error) *ApiError {
+	return NewApiError(ERROR_CODE_CONFLICT, reason)
+}
+
+func ErrTooManyRequests(reason error) *ApiError {
+	return NewApiError(ERROR_CODE_TOO_MANY_REQUESTS, reason)
+}
+
+func ErrInternal(reason error) *ApiError {
+	return NewApiError(ERROR_CODE_INTERNAL, reason)
+}
+
+func ErrServiceUnavailable(reason error) *ApiError {
+	return NewApiError(ERROR_CODE_SERVICE_UNAVAILABLE, reason)
+}
+
+// 错误链中是否有该错误码的 ApiError 。
+func IsErrorCode(err error, code int) bool {
+	var apiError *ApiError
+	return errors.As(err, &apiError) && apiError.Code == code
+}
+
+// 把任意错误转为 ApiError ，包装过的 ApiError 也能取出。
+func toApiError(err error) *ApiError {
+	var apiError *ApiError
+	if errors.As(err, &apiError) {
+		return apiError
+	}
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) {
+		if code, ok := httpErrorCodes[httpError.Code]; ok {
+			return NewApiError(code, err)
+		}
+		return &ApiError{
+			Code:     ERROR_CODE_UNKNOWN,
+			Message:  httpError.Message,
+			HttpCode: httpError.Code,
+			Reason:   err,
+		}
+	}
+	return &ApiError{
+		Code:     ERROR_CODE_UNKNOWN,
+		Message:  err.Error(),
+		HttpCode: http.StatusInternalServerError,
+		Reason:   err,
+	}
+}
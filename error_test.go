@@ -0,0 +1,69 @@
+package cjungo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestApiErrorCopy(t *testing.T) {
+	// 共享的错误变量不会被修改。
+	base := ErrValidation(ErrorDetail{Field: "name", Message: "不能为空"})
+	message := base.WithMessage("名称有误")
+	details := base.WithDetails(ErrorDetail{Field: "age", Message: "无效"})
+	if base.Message != "参数校验失败" || len(base.Details) != 1 {
+		t.Fatalf("原错误被修改为 %+v", base)
+	}
+	if message.Message != "名称有误" || message.Code != ERROR_CODE_VALIDATION || len(message.Details) != 1 {
+		t.Fatalf("WithMessage 为 %+v", message)
+	}
+	if len(details.Details) != 2 || details.Details[1].Field != "age" {
+		t.Fatalf("WithDetails 为 %+v", details)
+	}
+
+	// 有空余容量时也不共用底层数组。
+	base.Details = make([]ErrorDetail, 0, 4)
+	first := base.WithDetails(ErrorDetail{Field: "a"})
+	second := base.WithDetails(ErrorDetail{Field: "b"})
+	if len(base.Details) != 0 || first.Details[0].Field != "a" || second.Details[0].Field != "b" {
+		t.Fatalf("副本为 %+v %+v", first.Details, second.Details)
+	}
+}
+
+func TestRespBad(t *testing.T) {
+	logger := zerolog.Nop()
+	router := NewRouter(NewRouterDi{Logger: &logger})
+	router.GET("/plain", func(ctx HttpContext) error {
+		return ctx.RespBadF("缺少参数 %s", "id")
+	})
+	router.GET("/api", func(ctx HttpContext) error {
+		return ctx.RespBad(ErrNotFound(errors.New("x")))
+	})
+	get := func(path string) (int, *ApiError) {
+		rec := httptest.NewRecorder()
+		router.GetHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		result := &ApiError{}
+		if err := json.Unmarshal(rec.Body.Bytes(), result); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, result
+	}
+
+	// 普通错误按 BAD_REQUEST 返回，消息为错误内容。
+	code, result := get("/plain")
+	if code != http.StatusBadRequest || result.Code != ERROR_CODE_BAD_REQUEST || result.Name != "BAD_REQUEST" || result.I18nKey != "error.bad_request" {
+		t.Fatalf("/plain 为 %d %+v", code, result)
+	}
+	if result.Message != "缺少参数 id" {
+		t.Errorf("消息为 %v", result.Message)
+	}
+
+	// ApiError 原样返回。
+	if code, result := get("/api"); code != http.StatusNotFound || result.Code != ERROR_CODE_NOT_FOUND {
+		t.Fatalf("/api 为 %d %+v", code, result)
+	}
+}
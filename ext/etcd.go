@@ -2,14 +2,15 @@ package ext
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/coreos/etcd/mvcc/mvccpb"
 	"github.com/rs/zerolog"
-	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/dig"
 )
 
@@ -19,9 +20,12 @@ type EtcdPair struct {
 }
 
 type EtcdDiscovery struct {
-	client     *clientv3.Client
-	serverList sync.Map
-	Logger     *zerolog.Logger
+	client        *clientv3.Client
+	serverList    sync.Map
+	defaultPrefix string
+	ctx           context.Context
+	cancel        context.CancelFunc
+	Logger        *zerolog.Logger
 }
 
 type EtcdDiscoveryConf struct {
@@ -56,15 +60,47 @@ func NewEtcdDiscovery(di EtcdDiscoveryDi) (*EtcdDiscovery, error) {
 		return nil, err
 	}
 	di.Logger.Info().Str("action", "初始化完成").Msg("[ETCD]")
+	ctx, cancel := context.WithCancel(context.Background())
 	return &EtcdDiscovery{
-		client:     client,
-		serverList: sync.Map{},
-		Logger:     di.Logger,
+		client:        client,
+		serverList:    sync.Map{},
+		defaultPrefix: di.Conf.DefaultPrefix,
+		ctx:           ctx,
+		cancel:        cancel,
+		Logger:        di.Logger,
 	}, nil
 }
 
+// 作为生命周期组件启动时，监听 DefaultPrefix 。
+func (discovery *EtcdDiscovery) Start(ctx context.Context) error {
+	if len(discovery.defaultPrefix) > 0 {
+		return discovery.WatchService(discovery.defaultPrefix)
+	}
+	return nil
+}
+
+func (discovery *EtcdDiscovery) Stop(ctx context.Context) error {
+	return discovery.Close()
+}
+
+// 健康检查，任意一个节点可用即为健康。
+func (discovery *EtcdDiscovery) CheckHealth(ctx context.Context) error {
+	errs := []error{}
+	for _, endpoint := range discovery.client.Endpoints() {
+		if _, err := discovery.client.Status(ctx, endpoint); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+		} else {
+			return nil
+		}
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("没有可用的节点")
+	}
+	return errors.Join(errs...)
+}
+
 func (discovery *EtcdDiscovery) WatchService(prefix string) error {
-	resp, err := discovery.client.Get(context.Background(), prefix, clientv3.WithPrefix())
+	resp, err := discovery.client.Get(discovery.ctx, prefix, clientv3.WithPrefix())
 	if err != nil {
 		return err
 	}
@@ -87,7 +123,7 @@ func (discovery *EtcdDiscovery) WatchService(prefix string) error {
 }
 
 func (discovery *EtcdDiscovery) watch(prefix string) {
-	watchChan := discovery.client.Watch(context.Background(), prefix, clientv3.WithPrefix())
+	watchChan := discovery.client.Watch(discovery.ctx, prefix, clientv3.WithPrefix())
 	discovery.Logger.Info().
 		Str("action", "WATCH").
 		Str("prefix", prefix).
@@ -105,6 +141,7 @@ func (discovery *EtcdDiscovery) watch(prefix string) {
 }
 
 func (discovery *EtcdDiscovery) Close() error {
+	discovery.cancel()
 	return discovery.client.Close()
 }
 
@@ -2,17 +2,29 @@ package ext
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
+	"github.com/cjungo/cjungo"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
+// 密钥可以通过 CJUNGO_JWT_KEY 或 CJUNGO_JWT_KEY_FILE 配置。
+func getJwtKey() (string, error) {
+	key, ok, err := cjungo.LookupEnv("CJUNGO_JWT_KEY")
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("没有配置 CJUNGO_JWT_KEY")
+	}
+	return key, nil
+}
+
 func MakeJwtToken[T jwt.Claims](claims T) (string, error) {
-	key := os.Getenv("CJUNGO_JWT_KEY")
-	if len(key) <= 0 {
-		return "", fmt.Errorf("生成 TOKEN 失败，没有配置 CJUNGO_JWT_KEY")
+	key, err := getJwtKey()
+	if err != nil {
+		return "", fmt.Errorf("生成 TOKEN 失败，%v", err)
 	}
 
 	k := []byte(key)
@@ -25,9 +37,9 @@ func MakeJwtToken[T jwt.Claims](claims T) (string, error) {
 }
 
 func ParseJwtToken[T jwt.Claims](ctx echo.Context, claims T) (*jwt.Token, error) {
-	key := os.Getenv("CJUNGO_JWT_KEY")
-	if len(key) <= 0 {
-		return nil, fmt.Errorf("解析 TOKEN 失败，没有配置 CJUNGO_JWT_KEY")
+	key, err := getJwtKey()
+	if err != nil {
+		return nil, fmt.Errorf("解析 TOKEN 失败，%v", err)
 	}
 	request := ctx.Request()
 
@@ -36,7 +48,7 @@ func ParseJwtToken[T jwt.Claims](ctx echo.Context, claims T) (*jwt.Token, error)
 	if !strings.HasPrefix(auth, "Bearer ") {
 		// 其次找 Cookie: jwt 字段
 		if cookie, err := request.Cookie("jwt"); err != nil {
-			return nil, fmt.Errorf("不是有效的 JWT token: %v", err)
+			return nil, cjungo.ErrUnauthorized(fmt.Errorf("不是有效的 JWT token: %w", err))
 		} else {
 			auth = cookie.Value
 		}
@@ -50,7 +62,13 @@ func ParseJwtToken[T jwt.Claims](ctx echo.Context, claims T) (*jwt.Token, error)
 		return k, nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("解析 Token 失败, %v, %s", err, tokenString)
+		return nil, cjungo.ErrUnauthorized(fmt.Errorf("解析 Token 失败, %w, %s", err, tokenString))
+	}
+	// 认证主体加入请求日志
+	if hc, ok := ctx.(cjungo.HttpContext); ok {
+		if subject, err := claims.GetSubject(); err == nil && len(subject) > 0 {
+			hc.SetSubject(subject)
+		}
 	}
 	return token, nil
 }
@@ -1,6 +1,7 @@
 package ext
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sync"
@@ -8,12 +9,17 @@ import (
 
 	"github.com/cjungo/cjungo"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/dig"
 	"golang.org/x/net/websocket"
 )
 
 type MessageKind = string
 type MessageToken = any
 type MessageControllerProvide[T MessageToken] func(logger *zerolog.Logger) (*MessageController[T], error)
+type MessageControllerDiProvide[T MessageToken] func(di MessageControllerDi) (*MessageController[T], error)
 type MessageAuthAccess[T MessageToken] func(ctx cjungo.HttpContext) (T, error)
 type OnMessageRecv[T MessageToken] func(controller *MessageController[T], client *MessageClient[T], msg *Message[T]) error
 
@@ -40,18 +46,28 @@ const (
 )
 
 type Message[T MessageToken] struct {
-	ID     string      `json:"id"`
-	Kind   MessageKind `json:"kind"`
-	TimeAt time.Time   `json:"timeAt"`
-	To     T           `json:"to,omitempty"`
-	Group  T           `json:"group,omitempty"`
-	From   T           `json:"from,omitempty"`
-	Data   any         `json:"data,omitempty"`
+	ID     string            `json:"id"`
+	Kind   MessageKind       `json:"kind"`
+	TimeAt time.Time         `json:"timeAt"`
+	To     T                 `json:"to,omitempty"`
+	Group  T                 `json:"group,omitempty"`
+	From   T                 `json:"from,omitempty"`
+	Data   any               `json:"data,omitempty"`
+	Trace  map[string]string `json:"trace,omitempty"` // 发送方的追踪上下文（W3C traceparent）
 }
 
 type MessageClient[T MessageToken] struct {
 	Token T
 	Conn  *websocket.Conn
+	ctx   context.Context
+}
+
+// 当前处理的消息的上下文，包含消息的 span 。
+func (client *MessageClient[T]) Context() context.Context {
+	if client.ctx == nil {
+		return context.Background()
+	}
+	return client.ctx
 }
 
 func (client *MessageClient[T]) Call(coder MessageCoder[T], msg *Message[T]) error {
@@ -72,6 +88,8 @@ func (client *MessageClient[T]) Recv(coder MessageCoder[T], msg *Message[T]) err
 
 type MessageController[T MessageToken] struct {
 	logger      *zerolog.Logger
+	metrics     *cjungo.Metrics
+	tracing     *cjungo.Tracing
 	clients     sync.Map
 	groups      sync.Map
 	tokenAccess MessageAuthAccess[T]
@@ -79,15 +97,33 @@ type MessageController[T MessageToken] struct {
 	onRecv      OnMessageRecv[T]
 }
 
+type MessageControllerDi struct {
+	dig.In
+	Logger  *zerolog.Logger
+	Metrics *cjungo.Metrics `optional:"true"`
+	Tracing *cjungo.Tracing `optional:"true"`
+}
+
 type MessageControllerProviderConf[T MessageToken] struct {
 	TokenAccess MessageAuthAccess[T]
 	Coder       MessageCoder[T]
 	OnRecv      OnMessageRecv[T]
 }
 
+// 不记录指标和链路追踪，需要时使用 ProvideMessageControllerWithDi 。
 func ProvideMessageController[T MessageToken](
 	conf *MessageControllerProviderConf[T],
 ) MessageControllerProvide[T] {
+	provide := ProvideMessageControllerWithDi(conf)
+	return func(logger *zerolog.Logger) (*MessageController[T], error) {
+		return provide(MessageControllerDi{Logger: logger})
+	}
+}
+
+// 有 Metrics 、Tracing 时记录连接数和链路。
+func ProvideMessageControllerWithDi[T MessageToken](
+	conf *MessageControllerProviderConf[T],
+) MessageControllerDiProvide[T] {
 	coder := conf.Coder
 	if coder == nil {
 		coder = &MessageJsonCoder[T]{}
@@ -97,14 +133,14 @@ func ProvideMessageController[T MessageToken](
 		onRecv = defaultOnRecv
 	}
 
-	return func(
-		logger *zerolog.Logger,
-	) (*MessageController[T], error) {
+	return func(di MessageControllerDi) (*MessageController[T], error) {
 		if conf.TokenAccess == nil {
 			return nil, fmt.Errorf("TokenAccess 不可空")
 		}
 		return &MessageController[T]{
-			logger:      logger,
+			logger:      di.Logger,
+			metrics:     di.Metrics,
+			tracing:     di.Tracing,
 			clients:     sync.Map{},
 			groups:      sync.Map{},
 			tokenAccess: conf.TokenAccess,
@@ -145,9 +181,12 @@ func (controller *MessageController[T]) Dispatch(ctx cjungo.HttpContext) error {
 		client := MessageClient[T]{
 			Token: token,
 			Conn:  conn,
+			ctx:   ctx.Request().Context(),
 		}
 		controller.clients.Store(token, &client)
+		untrack := controller.metrics.TrackMessageClient()
 		defer func() {
+			untrack()
 			controller.clients.Delete(token)
 			conn.Close()
 		}()
@@ -188,17 +227,45 @@ func (controller *MessageController[T]) FindGroup(group T) ([]T, error) {
 }
 
 func (controller *MessageController[T]) handle(client *MessageClient[T]) error {
+	base := client.Context()
+	defer func() {
+		client.ctx = base
+	}()
 	for {
 		msg := Message[T]{}
 		if err := client.Recv(controller.coder, &msg); err != nil {
 			return err
 		}
-		if err := controller.onRecv(controller, client, &msg); err != nil {
+		if err := controller.recv(base, client, &msg); err != nil {
 			return err
 		}
 	}
 }
 
+// 每条消息一个 span ，链接到发送方的追踪上下文。
+func (controller *MessageController[T]) recv(base context.Context, client *MessageClient[T], msg *Message[T]) error {
+	options := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("message.id", msg.ID),
+			attribute.String("message.kind", msg.Kind),
+		),
+	}
+	if linked := trace.SpanContextFromContext(cjungo.ExtractTraceContext(context.Background(), msg.Trace)); linked.IsValid() {
+		options = append(options, trace.WithLinks(trace.Link{SpanContext: linked}))
+	}
+	ctx, span := controller.tracing.StartSpan(base, "message "+msg.Kind, options...)
+	defer span.End()
+
+	client.ctx = ctx
+	err := controller.onRecv(controller, client, msg)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
 func (controller *MessageController[T]) sendSingle(from *MessageClient[T], msg *Message[T]) error {
 	target, err := controller.FindClient(msg.To)
 	if err != nil {
@@ -209,6 +276,7 @@ func (controller *MessageController[T]) sendSingle(from *MessageClient[T], msg *
 		From: from.Token,
 	}
 	MoveField(msg, &response)
+	response.Trace = cjungo.InjectTraceContext(from.Context())
 	return target.Call(controller.coder, &response)
 }
 
@@ -230,6 +298,7 @@ func (controller *MessageController[T]) sendGroup(from *MessageClient[T], msg *M
 				From: from.Token,
 			}
 			MoveField(msg, &response)
+			response.Trace = cjungo.InjectTraceContext(from.Context())
 			if err := target.Call(controller.coder, &response); err != nil {
 				return err
 			}
@@ -0,0 +1,182 @@
+package ext
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cjungo/cjungo"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+)
+
+type TaskManager struct {
+	logger *zerolog.Logger
+	queue  *cjungo.TaskQueue
+}
+
+func NewTaskManager(
+	logger *zerolog.Logger,
+	queue *cjungo.TaskQueue,
+) *TaskManager {
+	return &TaskManager{
+		logger: logger,
+		queue:  queue,
+	}
+}
+
+type TaskRouteConf struct {
+	PathPrefix       string
+	PushMiddleware   []echo.MiddlewareFunc
+	IndexMiddleware  []echo.MiddlewareFunc
+	QueryMiddleware  []echo.MiddlewareFunc
+	CancelMiddleware []echo.MiddlewareFunc
+	EventMiddleware  []echo.MiddlewareFunc
+}
+
+// 挂载任务接口：
+// POST   {prefix}/:name       推送任务，请求体为参数，可选 ?delay=10s
+// GET    {prefix}             列出任务，可选 ?status=Ok,Failed
+// GET    {prefix}/:id         查询任务
+// DELETE {prefix}/:id         取消任务
+// GET    {prefix}/:id/events  SSE 推送任务状态变化
+func (manager *TaskManager) Route(
+	router cjungo.HttpRouterGroup,
+	conf *TaskRouteConf,
+) *TaskController {
+	controller := &TaskController{
+		queue:  manager.queue,
+		logger: manager.logger,
+	}
+	router.POST(fmt.Sprintf("%s/:name", conf.PathPrefix), controller.Push, conf.PushMiddleware...)
+	router.GET(conf.PathPrefix, controller.Index, conf.IndexMiddleware...)
+	router.GET(fmt.Sprintf("%s/:id", conf.PathPrefix), controller.Query, conf.QueryMiddleware...)
+	router.DELETE(fmt.Sprintf("%s/:id", conf.PathPrefix), controller.Cancel, conf.CancelMiddleware...)
+	router.SSE(fmt.Sprintf("%s/:id/events", conf.PathPrefix), controller.Events, conf.EventMiddleware...)
+
+	manager.logger.Info().
+		Str("action", "TaskFor").
+		Str("prefix", conf.PathPrefix).
+		Msg("[TASK]")
+
+	return controller
+}
+
+type TaskController struct {
+	queue  *cjungo.TaskQueue
+	logger *zerolog.Logger
+}
+
+func (controller *TaskController) Push(ctx cjungo.HttpContext) error {
+	name := ctx.Param("name")
+	param := cjungo.TaskActionParam{}
+	if ctx.Request().ContentLength != 0 {
+		if err := (&echo.DefaultBinder{}).BindBody(ctx, &param); err != nil {
+			return ctx.RespBad(err)
+		}
+	}
+
+	var id string
+	var err error
+	if delay := ctx.QueryParam("delay"); len(delay) > 0 {
+		d, parseErr := time.ParseDuration(delay)
+		if parseErr != nil {
+			return cjungo.ErrValidation(cjungo.ErrorDetail{Field: "delay", Message: parseErr.Error()})
+		}
+		id, err = controller.queue.PushTaskAfter(name, param, d)
+	} else {
+		id, err = controller.queue.PushTask(name, param)
+	}
+	if err != nil {
+		return respTaskError(ctx, err)
+	}
+	return ctx.Resp(id)
+}
+
+func (controller *TaskController) Index(ctx cjungo.HttpContext) error {
+	statuses := []cjungo.TaskStatus{}
+	if status := ctx.QueryParam("status"); len(status) > 0 {
+		for _, s := range strings.Split(status, ",") {
+			statuses = append(statuses, cjungo.TaskStatus(strings.TrimSpace(s)))
+		}
+	}
+	result, err := controller.queue.ListTasks(statuses...)
+	if err != nil {
+		return err
+	}
+	return ctx.Resp(result)
+}
+
+func (controller *TaskController) Query(ctx cjungo.HttpContext) error {
+	result, err := controller.queue.QueryTask(ctx.Param("id"))
+	if err != nil {
+		return respTaskError(ctx, err)
+	}
+	return ctx.Resp(result)
+}
+
+func (controller *TaskController) Cancel(ctx cjungo.HttpContext) error {
+	if err := controller.queue.CancelTask(ctx.Param("id")); err != nil {
+		return respTaskError(ctx, err)
+	}
+	return ctx.RespOk()
+}
+
+// 没有该任务为 404 ，队列已满为 429 ，队列已停止为 503 ，其他为 400 。
+func respTaskError(ctx cjungo.HttpContext, err error) error {
+	switch {
+	case errors.Is(err, cjungo.ErrTaskNotFound):
+		return cjungo.ErrNotFound(err)
+	case errors.Is(err, cjungo.ErrTaskQueueFull):
+		return cjungo.ErrTooManyRequests(err)
+	case errors.Is(err, cjungo.ErrTaskQueueStopped):
+		return cjungo.ErrServiceUnavailable(err)
+	}
+	return ctx.RespBad(err)
+}
+
+// 推送任务当前状态及之后的每次变化，任务结束后关闭。
+func (controller *TaskController) Events(ctx cjungo.HttpContext, tx chan cjungo.SseEvent, rx chan error) {
+	id := ctx.Param("id")
+	done := ctx.Request().Context().Done()
+
+	watcher, unwatch := controller.queue.Watch(id)
+	defer unwatch()
+
+	send := func(result *cjungo.TaskResult) bool {
+		select {
+		case tx <- cjungo.SseEvent{Event: "status", Data: result}:
+			return true
+		case <-done:
+			return false
+		case <-rx:
+			return false
+		}
+	}
+
+	result, err := controller.queue.QueryTask(id)
+	if err != nil {
+		select {
+		case tx <- cjungo.SseEvent{Event: "error", Data: err.Error()}:
+		case <-done:
+		}
+		return
+	}
+	if !send(result) || cjungo.IsTaskFinished(result.Status) {
+		return
+	}
+
+	for {
+		select {
+		case result := <-watcher:
+			if !send(result) || cjungo.IsTaskFinished(result.Status) {
+				return
+			}
+		case <-done:
+			return
+		case <-rx:
+			return
+		}
+	}
+}
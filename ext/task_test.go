@@ -0,0 +1,236 @@
+package ext
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cjungo/cjungo"
+	"github.com/rs/zerolog"
+)
+
+type taskTestResp struct {
+	Code    int                  `json:"code"`
+	Data    json.RawMessage      `json:"data"`
+	Details []cjungo.ErrorDetail `json:"details"`
+}
+
+// 挂载任务接口的测试服务器，gate 关闭前 wait 任务不结束。
+func newTaskTestServer(t *testing.T) (*httptest.Server, chan struct{}) {
+	t.Helper()
+	server, _, gate := newTaskTestServerWithConf(t, nil)
+	return server, gate
+}
+
+func newTaskTestServerWithConf(t *testing.T, conf *cjungo.TaskConfig) (*httptest.Server, *cjungo.TaskQueue, chan struct{}) {
+	t.Helper()
+	logger := zerolog.Nop()
+	queue, err := cjungo.NewTaskQueueHandle(func(queue *cjungo.TaskQueue) error { return nil })(cjungo.TaskQueueDi{Conf: conf, Logger: &logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gate := make(chan struct{})
+	queue.RegisterProcess("echo", func(action *cjungo.TaskAction) (cjungo.TaskResultMessage, error) {
+		return cjungo.TaskResultMessage(action.Param), nil
+	})
+	queue.RegisterProcess("wait", func(action *cjungo.TaskAction) (cjungo.TaskResultMessage, error) {
+		<-gate
+		return nil, nil
+	})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	router := cjungo.NewRouter(cjungo.NewRouterDi{Logger: &logger})
+	NewTaskManager(&logger, queue).Route(router, &TaskRouteConf{PathPrefix: "/tasks"})
+	server := httptest.NewServer(router.GetHandler())
+	t.Cleanup(func() {
+		server.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		queue.Stop(ctx)
+	})
+	return server, queue, gate
+}
+
+func doTaskRequest(t *testing.T, method string, url string, body string) (int, *taskTestResp) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	result := &taskTestResp{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, result
+}
+
+func queryTestTask(t *testing.T, server *httptest.Server, id string) *cjungo.TaskResult {
+	t.Helper()
+	status, resp := doTaskRequest(t, http.MethodGet, server.URL+"/tasks/"+id, "")
+	if status != http.StatusOK {
+		t.Fatalf("查询任务返回 %d", status)
+	}
+	result := &cjungo.TaskResult{}
+	if err := json.Unmarshal(resp.Data, result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func pushTestTask(t *testing.T, url string, body string) string {
+	t.Helper()
+	status, resp := doTaskRequest(t, http.MethodPost, url, body)
+	if status != http.StatusOK {
+		t.Fatalf("推送任务返回 %d", status)
+	}
+	var id string
+	if err := json.Unmarshal(resp.Data, &id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestTaskRoutePushQueryIndex(t *testing.T) {
+	server, _ := newTaskTestServer(t)
+
+	id := pushTestTask(t, server.URL+"/tasks/echo", `{"a":1}`)
+	deadline := time.Now().Add(5 * time.Second)
+	result := queryTestTask(t, server, id)
+	for result.Status != cjungo.TASK_STATUS_OK {
+		if time.Now().After(deadline) {
+			t.Fatalf("任务状态为 %s", result.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+		result = queryTestTask(t, server, id)
+	}
+	if result.Data["a"] != float64(1) {
+		t.Fatalf("任务结果为 %v", result.Data)
+	}
+
+	status, resp := doTaskRequest(t, http.MethodGet, server.URL+"/tasks?status=Ok,Failed", "")
+	if status != http.StatusOK {
+		t.Fatalf("列出任务返回 %d", status)
+	}
+	results := []*cjungo.TaskResult{}
+	if err := json.Unmarshal(resp.Data, &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ID != id {
+		t.Fatalf("列出任务 %v", results)
+	}
+
+	status, resp = doTaskRequest(t, http.MethodGet, server.URL+"/tasks/not-exists", "")
+	if status != http.StatusNotFound || resp.Code != cjungo.ERROR_CODE_NOT_FOUND {
+		t.Fatalf("查询不存在的任务返回 %d %d", status, resp.Code)
+	}
+	if status, _ := doTaskRequest(t, http.MethodDelete, server.URL+"/tasks/not-exists", ""); status != http.StatusNotFound {
+		t.Fatalf("取消不存在的任务返回 %d", status)
+	}
+}
+
+func TestTaskRoutePushQueueErrors(t *testing.T) {
+	capacity := 1
+	server, queue, gate := newTaskTestServerWithConf(t, &cjungo.TaskConfig{QueueCapacity: &capacity})
+	defer close(gate)
+
+	// 工作协程和队列都被占满后返回 429 。
+	status, resp := http.StatusOK, &taskTestResp{}
+	for i := 0; i < 3 && status == http.StatusOK; i++ {
+		status, resp = doTaskRequest(t, http.MethodPost, server.URL+"/tasks/wait", "")
+	}
+	if status != http.StatusTooManyRequests || resp.Code != cjungo.ERROR_CODE_TOO_MANY_REQUESTS {
+		t.Fatalf("队列已满返回 %d %d", status, resp.Code)
+	}
+
+	// 队列停止后返回 503 。
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	queue.Stop(ctx)
+	status, resp = doTaskRequest(t, http.MethodPost, server.URL+"/tasks/echo", "")
+	if status != http.StatusServiceUnavailable || resp.Code != cjungo.ERROR_CODE_SERVICE_UNAVAILABLE {
+		t.Fatalf("队列已停止返回 %d %d", status, resp.Code)
+	}
+}
+
+func TestTaskRouteDelayCancel(t *testing.T) {
+	server, _ := newTaskTestServer(t)
+
+	status, resp := doTaskRequest(t, http.MethodPost, server.URL+"/tasks/echo?delay=soon", "")
+	if status != http.StatusBadRequest || resp.Code != cjungo.ERROR_CODE_VALIDATION {
+		t.Fatalf("无效的 delay 返回 %d %d", status, resp.Code)
+	}
+	if len(resp.Details) != 1 || resp.Details[0].Field != "delay" {
+		t.Fatalf("字段错误 %v", resp.Details)
+	}
+
+	id := pushTestTask(t, server.URL+"/tasks/echo?delay=1h", "")
+	if result := queryTestTask(t, server, id); result.Status != cjungo.TASK_STATUS_DELAYED {
+		t.Fatalf("延迟任务状态为 %s", result.Status)
+	}
+	if status, _ := doTaskRequest(t, http.MethodDelete, server.URL+"/tasks/"+id, ""); status != http.StatusOK {
+		t.Fatalf("取消任务返回 %d", status)
+	}
+	if result := queryTestTask(t, server, id); result.Status != cjungo.TASK_STATUS_CANCELLED {
+		t.Fatalf("取消后状态为 %s", result.Status)
+	}
+	// 已结束的任务不可取消。
+	if status, _ := doTaskRequest(t, http.MethodDelete, server.URL+"/tasks/"+id, ""); status != http.StatusBadRequest {
+		t.Fatalf("重复取消返回 %d", status)
+	}
+}
+
+func TestTaskRouteEvents(t *testing.T) {
+	server, gate := newTaskTestServer(t)
+	id := pushTestTask(t, server.URL+"/tasks/wait", "")
+
+	resp, err := http.Get(server.URL + "/tasks/" + id + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Content-Type 为 %s", contentType)
+	}
+
+	// 先推送当前状态，任务结束后推送最终状态并关闭。
+	statuses := []cjungo.TaskStatus{}
+	scanner := bufio.NewScanner(resp.Body)
+	isFirst := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		result := &cjungo.TaskResult{}
+		if err := json.Unmarshal([]byte(data), result); err != nil {
+			t.Fatal(err)
+		}
+		statuses = append(statuses, result.Status)
+		if isFirst {
+			isFirst = false
+			close(gate)
+		}
+	}
+	if len(statuses) < 2 || statuses[len(statuses)-1] != cjungo.TASK_STATUS_OK {
+		t.Fatalf("推送的状态 %v", statuses)
+	}
+	if cjungo.IsTaskFinished(statuses[0]) {
+		t.Fatalf("首个状态 %s 不应已结束", statuses[0])
+	}
+}
@@ -1,14 +1,93 @@
 package ext
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+)
 
 func Tick(duration time.Duration, action func() error) error {
+	return TickContext(context.Background(), duration, action)
+}
+
+func TickContext(ctx context.Context, duration time.Duration, action func() error) error {
+	ticker := time.NewTicker(duration)
+	defer ticker.Stop()
 	for {
 		select {
-		case <-time.Tick(duration):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
 			if err := action(); err != nil {
 				return err
 			}
 		}
 	}
 }
+
+// 定时器生命周期组件，出错时记录日志并继续。
+type Ticker struct {
+	name     string
+	duration time.Duration
+	action   func() error
+	logger   *zerolog.Logger
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewTicker(
+	logger *zerolog.Logger,
+	name string,
+	duration time.Duration,
+	action func() error,
+) *Ticker {
+	return &Ticker{
+		name:     name,
+		duration: duration,
+		action:   action,
+		logger:   logger,
+	}
+}
+
+func (ticker *Ticker) Name() string {
+	return ticker.name
+}
+
+func (ticker *Ticker) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	ticker.cancel = cancel
+	ticker.done = make(chan struct{})
+	go func() {
+		defer close(ticker.done)
+		TickContext(ctx, ticker.duration, func() error {
+			if err := ticker.action(); err != nil {
+				ticker.logger.Error().
+					Str("action", "定时任务出错").
+					Str("name", ticker.name).
+					Err(err).
+					Msg("[TICK]")
+			}
+			return nil
+		})
+	}()
+	ticker.logger.Info().
+		Str("action", "启动").
+		Str("name", ticker.name).
+		Dur("duration", ticker.duration).
+		Msg("[TICK]")
+	return nil
+}
+
+func (ticker *Ticker) Stop(ctx context.Context) error {
+	if ticker.cancel == nil {
+		return nil
+	}
+	ticker.cancel()
+	select {
+	case <-ticker.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,280 @@
+package cjungo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.uber.org/dig"
+)
+
+type HealthConf struct {
+	Timeout    *time.Duration `env:"CJUNGO_HEALTH_TIMEOUT" default:"2s" min:"1ms"`  // 单个检查的默认超时
+	CacheTTL   *time.Duration `env:"CJUNGO_HEALTH_CACHE_TTL" default:"1s" min:"0s"` // 检查结果的缓存时间，为 0 时不缓存
+	DrainDelay *time.Duration `env:"CJUNGO_HEALTH_DRAIN_DELAY" min:"0s"`            // 关闭时 readyz 失败后等待多久再关闭服务器，让负载均衡摘除实例
+}
+
+// 健康检查，返回 nil 表示健康。
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+type HealthCheckFunc func(ctx context.Context) error
+
+func (check HealthCheckFunc) CheckHealth(ctx context.Context) error {
+	return check(ctx)
+}
+
+type HealthCheck struct {
+	Name       string
+	Checker    HealthChecker
+	Timeout    time.Duration // 为 0 时使用 HealthConf.Timeout
+	IsLiveness bool          // 为 true 时同时用于 livez ，否则只用于 readyz
+}
+
+// 通过 dig 值组 `group:"health"` 提供健康检查。
+type HealthCheckOut struct {
+	dig.Out
+	Check HealthCheck `group:"health"`
+}
+
+// 提供健康检查，组件 T 需实现 HealthChecker ，如 *db.MySql 、*ext.EtcdDiscovery 。
+func ProvideHealthCheck[T HealthChecker](container DiContainer, name string) error {
+	return provideHealthCheck[T](container, name, false)
+}
+
+// 同 ProvideHealthCheck ，同时用于 livez ，检查失败时应重启进程。
+func ProvideLivenessCheck[T HealthChecker](container DiContainer, name string) error {
+	return provideHealthCheck[T](container, name, true)
+}
+
+func provideHealthCheck[T HealthChecker](container DiContainer, name string, isLiveness bool) error {
+	return container.Provide(func(v T) HealthCheckOut {
+		return HealthCheckOut{
+			Check: HealthCheck{Name: name, Checker: v, IsLiveness: isLiveness},
+		}
+	})
+}
+
+const (
+	HEALTH_STATUS_OK   = "ok"
+	HEALTH_STATUS_FAIL = "fail"
+)
+
+type HealthCheckResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+	IsCached bool   `json:"isCached"`
+}
+
+type HealthReport struct {
+	Status     string              `json:"status"`
+	IsDraining bool                `json:"isDraining"`
+	Checks     []HealthCheckResult `json:"checks"`
+}
+
+type healthEntry struct {
+	HealthCheck
+	mutex   sync.Mutex
+	result  HealthCheckResult
+	checked time.Time
+}
+
+// 健康检查注册表，挂载 /healthz（全部检查）、/readyz（就绪）、/livez（存活）。
+type HealthRegistry struct {
+	logger     *zerolog.Logger
+	timeout    time.Duration
+	cacheTTL   time.Duration
+	drainDelay time.Duration
+	entries    []*healthEntry
+	mutex      sync.RWMutex
+	isDraining atomic.Bool
+}
+
+type HealthRegistryDi struct {
+	dig.In
+	Conf   *HealthConf `optional:"true"`
+	Router HttpRouter
+	Admin  *AdminRouter  `optional:"true"`
+	Queue  *TaskQueue    `optional:"true"`
+	Checks []HealthCheck `group:"health"`
+	Logger *zerolog.Logger
+}
+
+func NewHealthRegistry(di HealthRegistryDi) *HealthRegistry {
+	if di.Conf == nil {
+		di.Conf = NewDefaultConf[HealthConf]()
+	}
+	registry := &HealthRegistry{
+		logger:     di.Logger,
+		timeout:    GetOrDefault(di.Conf.Timeout, 2*time.Second),
+		cacheTTL:   GetOrDefault(di.Conf.CacheTTL, time.Second),
+		drainDelay: GetOrDefault(di.Conf.DrainDelay, 0),
+		entries:    []*healthEntry{},
+	}
+	if di.Queue != nil {
+		registry.Register(HealthCheck{Name: "task", Checker: di.Queue})
+	}
+	for _, check := range di.Checks {
+		registry.Register(check)
+	}
+
+	router := DiagnosticsRouter(di.Router, di.Admin)
+	router.GET("/healthz", registry.handle(func(check *HealthCheck) bool { return true }, false))
+	router.GET("/readyz", registry.handle(func(check *HealthCheck) bool { return true }, true))
+	router.GET("/livez", registry.handle(func(check *HealthCheck) bool { return check.IsLiveness }, false))
+	return registry
+}
+
+func LoadHealthConfFromEnv(logger *zerolog.Logger) (*HealthConf, error) {
+	logger.Info().Str("action", "通过环境变量配置健康检查").Msg("[HEALTH]")
+	conf := &HealthConf{}
+	if err := LoadConf(conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func (registry *HealthRegistry) Register(check HealthCheck) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.entries = append(registry.entries, &healthEntry{HealthCheck: check})
+	registry.logger.Info().Str("action", "注册健康检查").Str("name", check.Name).Msg("[HEALTH]")
+}
+
+func (registry *HealthRegistry) RegisterFunc(name string, check HealthCheckFunc) {
+	registry.Register(HealthCheck{Name: name, Checker: check})
+}
+
+// 注册同时用于 livez 的检查。
+func (registry *HealthRegistry) RegisterLivenessFunc(name string, check HealthCheckFunc) {
+	registry.Register(HealthCheck{Name: name, Checker: check, IsLiveness: true})
+}
+
+// 关闭时调用，之后 readyz 返回失败。
+func (registry *HealthRegistry) SetDraining(isDraining bool) {
+	registry.isDraining.Store(isDraining)
+}
+
+func (registry *HealthRegistry) IsDraining() bool {
+	return registry.isDraining.Load()
+}
+
+// 并发执行满足 filter 的检查，isReadiness 为 true 时关闭中视为失败。
+func (registry *HealthRegistry) Check(ctx context.Context, filter func(check *HealthCheck) bool, isReadiness bool) *HealthReport {
+	registry.mutex.RLock()
+	entries := []*healthEntry{}
+	for _, entry := range registry.entries {
+		if filter(&entry.HealthCheck) {
+			entries = append(entries, entry)
+		}
+	}
+	registry.mutex.RUnlock()
+
+	report := &HealthReport{
+		Status:     HEALTH_STATUS_OK,
+		IsDraining: registry.IsDraining(),
+		Checks:     make([]HealthCheckResult, len(entries)),
+	}
+	wg := sync.WaitGroup{}
+	for i, entry := range entries {
+		wg.Add(1)
+		go func(i int, entry *healthEntry) {
+			defer wg.Done()
+			report.Checks[i] = registry.run(ctx, entry)
+		}(i, entry)
+	}
+	wg.Wait()
+
+	sort.SliceStable(report.Checks, func(i, j int) bool {
+		return report.Checks[i].Name < report.Checks[j].Name
+	})
+	for _, result := range report.Checks {
+		if result.Status != HEALTH_STATUS_OK {
+			report.Status = HEALTH_STATUS_FAIL
+		}
+	}
+	if isReadiness && report.IsDraining {
+		report.Status = HEALTH_STATUS_FAIL
+	}
+	return report
+}
+
+// 同一检查同时只执行一次，缓存期内直接返回上次结果。
+func (registry *HealthRegistry) run(ctx context.Context, entry *healthEntry) HealthCheckResult {
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	if registry.cacheTTL > 0 && !entry.checked.IsZero() && time.Since(entry.checked) < registry.cacheTTL {
+		result := entry.result
+		result.IsCached = true
+		return result
+	}
+
+	timeout := entry.Timeout
+	if timeout <= 0 {
+		timeout = registry.timeout
+	}
+	// 结果会被缓存，不受请求取消影响，只按检查的超时结束。
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	start := time.Now()
+	// 检查可能不响应 ctx ，超时后不再等待。
+	errChan := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- fmt.Errorf("健康检查异常: %v", r)
+			}
+		}()
+		errChan <- entry.Checker.CheckHealth(ctx)
+	}()
+	var err error
+	select {
+	case err = <-errChan:
+	case <-ctx.Done():
+		err = fmt.Errorf("健康检查超时: %w", ctx.Err())
+	}
+
+	result := HealthCheckResult{
+		Name:     entry.Name,
+		Status:   HEALTH_STATUS_OK,
+		Duration: time.Since(start).String(),
+	}
+	if err != nil {
+		result.Status = HEALTH_STATUS_FAIL
+		result.Error = err.Error()
+		registry.logger.Warn().Str("action", "健康检查失败").Str("name", entry.Name).Err(err).Msg("[HEALTH]")
+	}
+	entry.result = result
+	entry.checked = time.Now()
+	return result
+}
+
+func (registry *HealthRegistry) handle(filter func(check *HealthCheck) bool, isReadiness bool) HttpHandlerFunc {
+	return func(ctx HttpContext) error {
+		report := registry.Check(ctx.Request().Context(), filter, isReadiness)
+		code := http.StatusOK
+		if report.Status != HEALTH_STATUS_OK {
+			code = http.StatusServiceUnavailable
+		}
+		return ctx.JSON(code, report)
+	}
+}
+
+// 进入关闭状态，等待 DrainDelay 让负载均衡摘除实例。
+func (registry *HealthRegistry) drain() {
+	registry.SetDraining(true)
+	if registry.drainDelay > 0 {
+		registry.logger.Info().Str("action", "等待摘除实例").Dur("delay", registry.drainDelay).Msg("[HEALTH]")
+		time.Sleep(registry.drainDelay)
+	}
+}
@@ -0,0 +1,141 @@
+package cjungo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.uber.org/dig"
+)
+
+type testHealthChecker struct {
+	err error
+}
+
+func (checker *testHealthChecker) CheckHealth(ctx context.Context) error {
+	return checker.err
+}
+
+func newTestHealthRegistry(t *testing.T, conf *HealthConf) *HealthRegistry {
+	t.Helper()
+	logger := zerolog.Nop()
+	return NewHealthRegistry(HealthRegistryDi{
+		Conf:   conf,
+		Router: NewRouter(NewRouterDi{Logger: &logger}),
+		Logger: &logger,
+	})
+}
+
+func TestHealthRegistryDetachRequestCancel(t *testing.T) {
+	ttl := time.Minute
+	registry := newTestHealthRegistry(t, &HealthConf{CacheTTL: &ttl})
+	registry.RegisterFunc("slow", func(ctx context.Context) error {
+		select {
+		case <-time.After(20 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	// 请求已取消时检查仍然执行完，不缓存失败结果。
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	all := func(check *HealthCheck) bool { return true }
+	if report := registry.Check(ctx, all, false); report.Status != HEALTH_STATUS_OK {
+		t.Fatalf("检查结果为 %+v", report)
+	}
+	report := registry.Check(context.Background(), all, false)
+	if report.Status != HEALTH_STATUS_OK || !report.Checks[0].IsCached {
+		t.Fatalf("检查结果为 %+v", report)
+	}
+}
+
+func TestHealthRegistryTimeout(t *testing.T) {
+	ttl := time.Duration(0)
+	registry := newTestHealthRegistry(t, &HealthConf{CacheTTL: &ttl})
+	block := make(chan struct{})
+	defer close(block)
+	registry.Register(HealthCheck{
+		Name:    "block",
+		Timeout: 10 * time.Millisecond,
+		Checker: HealthCheckFunc(func(ctx context.Context) error {
+			<-block // 不响应 ctx
+			return nil
+		}),
+	})
+	registry.RegisterFunc("panic", func(ctx context.Context) error {
+		panic("boom")
+	})
+
+	report := registry.Check(context.Background(), func(check *HealthCheck) bool { return true }, false)
+	if report.Status != HEALTH_STATUS_FAIL || len(report.Checks) != 2 {
+		t.Fatalf("检查结果为 %+v", report)
+	}
+	if !strings.Contains(report.Checks[0].Error, "健康检查超时") || !strings.Contains(report.Checks[1].Error, "boom") {
+		t.Fatalf("检查结果为 %+v", report.Checks)
+	}
+}
+
+func TestHealthRegistryLiveness(t *testing.T) {
+	logger := zerolog.Nop()
+	container := &DiSimpleContainer{Container: dig.New()}
+	if err := container.Provides(
+		func() *zerolog.Logger { return &logger },
+		func() *testHealthChecker { return &testHealthChecker{} },
+		NewRouter,
+		NewHealthRegistry,
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := ProvideLivenessCheck[*testHealthChecker](container, "live"); err != nil {
+		t.Fatal(err)
+	}
+	if err := container.Provide(func() HealthCheckOut {
+		return HealthCheckOut{Check: HealthCheck{Name: "ready", Checker: &testHealthChecker{err: context.DeadlineExceeded}}}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var handler http.Handler
+	if err := container.Invoke(func(router HttpRouter, registry *HealthRegistry) {
+		handler = router.GetHandler()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) (int, *HealthReport) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		report := &HealthReport{}
+		if err := json.Unmarshal(rec.Body.Bytes(), report); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, report
+	}
+
+	// livez 只执行标记为存活检查的。
+	code, report := get("/livez")
+	if code != http.StatusOK || len(report.Checks) != 1 || report.Checks[0].Name != "live" {
+		t.Fatalf("livez 为 %d %+v", code, report)
+	}
+	if code, report := get("/readyz"); code != http.StatusServiceUnavailable || len(report.Checks) != 2 {
+		t.Fatalf("readyz 为 %d %+v", code, report)
+	}
+}
+
+func TestHealthRegistryDraining(t *testing.T) {
+	registry := newTestHealthRegistry(t, nil)
+	registry.SetDraining(true)
+	all := func(check *HealthCheck) bool { return true }
+	if report := registry.Check(context.Background(), all, true); report.Status != HEALTH_STATUS_FAIL || !report.IsDraining {
+		t.Fatalf("readyz 为 %+v", report)
+	}
+	if report := registry.Check(context.Background(), all, false); report.Status != HEALTH_STATUS_OK {
+		t.Fatalf("healthz 为 %+v", report)
+	}
+}
@@ -0,0 +1,99 @@
+package cjungo
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+
+	"go.uber.org/dig"
+)
+
+// 生命周期组件，由 Application 启动和停止。
+type Lifecycle interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// 可选，提供组件名称用于日志和错误信息。
+type LifecycleNamed interface {
+	Name() string
+}
+
+// 通过 dig 值组 `group:"lifecycle"` 提供生命周期组件。
+type LifecycleOut struct {
+	dig.Out
+	Lifecycle Lifecycle `group:"lifecycle"`
+}
+
+type lifecycleComponent struct {
+	Lifecycle
+	name string
+	seq  *atomic.Int64
+}
+
+func (component *lifecycleComponent) Name() string {
+	return component.name
+}
+
+var lifecycleSeq atomic.Int64
+
+// 提供构造函数，并把结果加入生命周期组件。
+// 组件按构造顺序（依赖先于依赖者）启动，按逆序停止。
+func ProvideLifecycle[T Lifecycle](container DiContainer, constructor any, opts ...dig.ProvideOption) error {
+	seq := &atomic.Int64{}
+	opts = append(opts, dig.WithProviderCallback(func(ci dig.CallbackInfo) {
+		if ci.Error == nil {
+			seq.Store(lifecycleSeq.Add(1))
+		}
+	}))
+	if err := container.Provide(constructor, opts...); err != nil {
+		return err
+	}
+	return container.Provide(func(v T) LifecycleOut {
+		return LifecycleOut{
+			Lifecycle: &lifecycleComponent{
+				Lifecycle: v,
+				name:      LifecycleName(v),
+				seq:       seq,
+			},
+		}
+	})
+}
+
+func LifecycleName(v Lifecycle) string {
+	if named, ok := v.(LifecycleNamed); ok {
+		return named.Name()
+	}
+	return GetTypeName(v)
+}
+
+// 通过 ProvideLifecycle 提供的组件按构造顺序排列，
+// 直接提供到值组的组件没有构造顺序，按名称排在其后。
+func sortLifecycles(items []Lifecycle) []Lifecycle {
+	result := make([]Lifecycle, len(items))
+	copy(result, items)
+	seqOf := func(v Lifecycle) int64 {
+		if component, ok := v.(*lifecycleComponent); ok {
+			return component.seq.Load()
+		}
+		return 0
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		si, sj := seqOf(result[i]), seqOf(result[j])
+		if si == 0 || sj == 0 {
+			if si != sj {
+				return sj == 0
+			}
+			return LifecycleName(result[i]) < LifecycleName(result[j])
+		}
+		return si < sj
+	})
+	return result
+}
+
+func unwrapLifecycle(v Lifecycle) Lifecycle {
+	if component, ok := v.(*lifecycleComponent); ok {
+		return component.Lifecycle
+	}
+	return v
+}
@@ -1,6 +1,7 @@
 package cjungo
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -11,13 +12,14 @@ import (
 )
 
 type LoggerConf struct {
-	IsOutputConsole bool
+	IsOutputConsole bool   `env:"CJUNGO_LOG_IS_OUTPUT_CONSOLE" default:"true"`
+	Level           string `env:"CJUNGO_LOG_LEVEL"` // trace、debug、info、warn、error ，为空时不限制
 
-	Filename   string
-	MaxSize    *int
-	MaxBackups *int
-	MaxAge     *int
-	IsCompress *bool
+	Filename   string `env:"CJUNGO_LOG_FILENAME"`
+	MaxSize    *int   `env:"CJUNGO_LOG_MAX_SIZE" default:"4" min:"1"`
+	MaxBackups *int   `env:"CJUNGO_LOG_MAX_BACKUPS" default:"3" min:"0"`
+	MaxAge     *int   `env:"CJUNGO_LOG_MAX_AGE" default:"14" min:"0"`
+	IsCompress *bool  `env:"CJUNGO_LOG_IS_COMPRESS" default:"true"`
 }
 
 type NewLoggerDi struct {
@@ -54,6 +56,10 @@ func NewLogger(di NewLoggerDi) *zerolog.Logger {
 		writers = append(writers, consoleWriter)
 	}
 
+	if di.Conf != nil && len(di.Conf.Level) > 0 {
+		zerolog.SetGlobalLevel(parseLoggerLevel(di.Conf.Level))
+	}
+
 	multiWriter := zerolog.MultiLevelWriter(writers...)
 	logger := zerolog.New(multiWriter).With().Timestamp().Logger()
 
@@ -66,41 +72,29 @@ func NewLogger(di NewLoggerDi) *zerolog.Logger {
 	return &logger
 }
 
-func LoadLoggerConfFromEnv() (*LoggerConf, error) {
-	conf := &LoggerConf{
-		IsOutputConsole: true,
-	}
-	filename := os.Getenv("CJUNGO_LOG_FILENAME")
-	if strings.HasSuffix(filename, ".log") {
-		conf.Filename = filename
+func (conf *LoggerConf) Validate() error {
+	if _, err := zerolog.ParseLevel(conf.Level); err != nil {
+		return fmt.Errorf("日志级别 %s 无效", conf.Level)
 	}
+	return nil
+}
 
-	if err := GetEnvBool("CJUNGO_LOG_IS_OUTPUT_CONSOLE", func(v bool) {
-		conf.IsOutputConsole = v
-	}); err != nil {
-		return nil, err
+// 为空时使用 zerolog 的默认级别 trace ，即不限制。
+func parseLoggerLevel(text string) zerolog.Level {
+	level, err := zerolog.ParseLevel(text)
+	if err != nil || level == zerolog.NoLevel {
+		return zerolog.TraceLevel
 	}
+	return level
+}
 
-	if err := GetEnvInt("CJUNGO_LOG_MAX_SIZE", func(v int) {
-		conf.MaxSize = &v
-	}); err != nil {
-		return nil, err
-	}
-	if err := GetEnvInt("CJUNGO_LOG_MAX_BACKUPS", func(v int) {
-		conf.MaxBackups = &v
-	}); err != nil {
-		return nil, err
-	}
-	if err := GetEnvInt("CJUNGO_LOG_MAX_AGE", func(v int) {
-		conf.MaxAge = &v
-	}); err != nil {
+func LoadLoggerConfFromEnv() (*LoggerConf, error) {
+	conf := &LoggerConf{}
+	if err := LoadConf(conf); err != nil {
 		return nil, err
 	}
-	if err := GetEnvBool("CJUNGO_LOG_IS_COMPRESS", func(v bool) {
-		conf.IsCompress = &v
-	}); err != nil {
-		return nil, err
+	if !strings.HasSuffix(conf.Filename, ".log") {
+		conf.Filename = ""
 	}
-
 	return conf, nil
 }
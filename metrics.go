@@ -0,0 +1,256 @@
+package cjungo
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"go.uber.org/dig"
+)
+
+type MetricsConf struct {
+	Namespace *string   `env:"CJUNGO_METRICS_NAMESPACE" default:"cjungo"`
+	Path      *string   `env:"CJUNGO_METRICS_PATH" default:"/metrics"`
+	Buckets   []float64 `env:"CJUNGO_METRICS_BUCKETS"`                   // 耗时直方图的分桶（秒），如 0.01,0.1,1 ，为空时使用默认值
+	IsRuntime bool      `env:"CJUNGO_METRICS_IS_RUNTIME" default:"true"` // 输出 Go 运行时和进程指标
+}
+
+const (
+	METRICS_STREAM_SSE          = "sse"
+	METRICS_STREAM_LONG_POLLING = "long_polling"
+)
+
+// Prometheus 指标，方法允许 nil 接收者，没有启用时不记录。
+// 自定义指标可以注册到 Registry 。
+type Metrics struct {
+	Registry       *prometheus.Registry
+	namespace      string
+	path           string
+	logger         *zerolog.Logger
+	httpRequests   *prometheus.CounterVec
+	httpDuration   *prometheus.HistogramVec
+	dbDuration     *prometheus.HistogramVec
+	taskDuration   *prometheus.HistogramVec
+	taskFailures   *prometheus.CounterVec
+	streams        *prometheus.GaugeVec
+	messageClients prometheus.Gauge
+}
+
+type MetricsDi struct {
+	dig.In
+	Conf   *MetricsConf `optional:"true"`
+	Logger *zerolog.Logger
+}
+
+// 没有配置 MetricsConf 时返回 nil ，不输出指标。
+func NewMetrics(di MetricsDi) (*Metrics, error) {
+	if di.Conf == nil {
+		di.Logger.Info().Str("action", "没有启用指标").Msg("[METRICS]")
+		return nil, nil
+	}
+	namespace := GetOrDefault(di.Conf.Namespace, "cjungo")
+	buckets := di.Conf.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	metrics := &Metrics{
+		Registry:  prometheus.NewRegistry(),
+		namespace: namespace,
+		path:      GetOrDefault(di.Conf.Path, "/metrics"),
+		logger:    di.Logger,
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP 请求数",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP 请求耗时",
+			Buckets:   buckets,
+		}, []string{"method", "route", "status"}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      "query_duration_seconds",
+			Help:      "数据库查询耗时",
+			Buckets:   buckets,
+		}, []string{"db", "operation", "status"}),
+		taskDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "task",
+			Name:      "process_duration_seconds",
+			Help:      "任务处理耗时",
+			Buckets:   buckets,
+		}, []string{"name", "status"}),
+		taskFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "task",
+			Name:      "failures_total",
+			Help:      "任务处理失败数",
+		}, []string{"name"}),
+		streams: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "active_streams",
+			Help:      "活动的 SSE 、LongPolling 连接数",
+		}, []string{"kind"}),
+		messageClients: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "message",
+			Name:      "connected_clients",
+			Help:      "已连接的消息客户端数",
+		}),
+	}
+
+	items := []prometheus.Collector{
+		metrics.httpRequests,
+		metrics.httpDuration,
+		metrics.dbDuration,
+		metrics.taskDuration,
+		metrics.taskFailures,
+		metrics.streams,
+		metrics.messageClients,
+	}
+	if di.Conf.IsRuntime {
+		items = append(items,
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+	for _, item := range items {
+		if err := metrics.Registry.Register(item); err != nil {
+			return nil, err
+		}
+	}
+	di.Logger.Info().Str("action", "启用指标").Str("path", metrics.path).Msg("[METRICS]")
+	return metrics, nil
+}
+
+func LoadMetricsConfFromEnv(logger *zerolog.Logger) (*MetricsConf, error) {
+	logger.Info().Str("action", "通过环境变量配置指标").Msg("[METRICS]")
+	conf := &MetricsConf{}
+	if err := LoadConf(conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// 以 Prometheus 文本格式输出指标。
+func (metrics *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})
+}
+
+func (metrics *Metrics) mount(router HttpRouter) {
+	if metrics == nil {
+		return
+	}
+	handler := echo.WrapHandler(metrics.Handler())
+	router.GET(metrics.path, func(ctx HttpContext) error {
+		return handler(ctx)
+	})
+}
+
+// 按路由和状态码统计请求数和耗时，错误已由内层交给 HTTPErrorHandler ，状态码为最终状态码。
+func (metrics *Metrics) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			route := c.Path()
+			if len(route) == 0 {
+				route = "unknown"
+			}
+			labels := prometheus.Labels{
+				"method": c.Request().Method,
+				"route":  route,
+				"status": strconv.Itoa(c.Response().Status),
+			}
+			metrics.httpRequests.With(labels).Inc()
+			metrics.httpDuration.With(labels).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
+
+// 记录数据库查询耗时，operation 取 SQL 的第一个关键字。
+func (metrics *Metrics) ObserveDbQuery(db string, sql string, duration time.Duration, err error) {
+	if metrics == nil {
+		return
+	}
+	operation := "other"
+	if fields := strings.Fields(sql); len(fields) > 0 {
+		switch keyword := strings.ToLower(fields[0]); keyword {
+		case "select", "insert", "update", "delete", "create", "alter", "drop", "begin", "commit", "rollback", "savepoint":
+			operation = keyword
+		}
+	}
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	metrics.dbDuration.WithLabelValues(db, operation, status).Observe(duration.Seconds())
+}
+
+// 记录一个消息客户端连接，返回断开时调用的函数。
+func (metrics *Metrics) TrackMessageClient() func() {
+	if metrics == nil {
+		return func() {}
+	}
+	metrics.messageClients.Inc()
+	return metrics.messageClients.Dec
+}
+
+func (metrics *Metrics) trackStream(kind string) func() {
+	if metrics == nil {
+		return func() {}
+	}
+	gauge := metrics.streams.WithLabelValues(kind)
+	gauge.Inc()
+	return gauge.Dec
+}
+
+func (metrics *Metrics) observeTask(name string, status TaskStatus, duration time.Duration) {
+	if metrics == nil {
+		return
+	}
+	metrics.taskDuration.WithLabelValues(name, string(status)).Observe(duration.Seconds())
+	switch status {
+	case TASK_STATUS_FAILED, TASK_STATUS_TIMEOUT, TASK_STATUS_RETRY, TASK_STATUS_DEAD:
+		metrics.taskFailures.WithLabelValues(name).Inc()
+	}
+}
+
+// 任务队列的积压数和容量。
+func (metrics *Metrics) watchTaskQueue(queue *TaskQueue) {
+	if metrics == nil {
+		return
+	}
+	for _, item := range []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metrics.namespace,
+			Subsystem: "task",
+			Name:      "queue_depth",
+			Help:      "任务队列积压数",
+		}, func() float64 { return float64(len(queue.unprocessed)) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metrics.namespace,
+			Subsystem: "task",
+			Name:      "queue_capacity",
+			Help:      "任务队列容量",
+		}, func() float64 { return float64(cap(queue.unprocessed)) }),
+	} {
+		if err := metrics.Registry.Register(item); err != nil {
+			metrics.logger.Error().Str("action", "注册队列指标失败").Err(err).Msg("[METRICS]")
+		}
+	}
+}
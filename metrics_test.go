@@ -0,0 +1,213 @@
+package cjungo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+)
+
+func newTestMetrics(t *testing.T) *Metrics {
+	t.Helper()
+	logger := zerolog.Nop()
+	metrics, err := NewMetrics(MetricsDi{Conf: &MetricsConf{}, Logger: &logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metrics
+}
+
+// 指标值异步更新时等待达到 want 。
+func waitMetricValue(t *testing.T, collector prometheus.Collector, want float64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := testutil.ToFloat64(collector)
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("指标为 %v，应为 %v", got, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestNewMetricsOptIn(t *testing.T) {
+	logger := zerolog.Nop()
+	metrics, err := NewMetrics(MetricsDi{Logger: &logger})
+	if err != nil || metrics != nil {
+		t.Fatalf("没有配置时为 %v %v", metrics, err)
+	}
+
+	// 没有启用时不记录，也不挂载 /metrics 。
+	metrics.ObserveDbQuery("sqlite", "select 1", time.Millisecond, nil)
+	metrics.TrackMessageClient()()
+	router := NewRouter(NewRouterDi{Logger: &logger, Metrics: metrics})
+	rec := httptest.NewRecorder()
+	router.GetHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("/metrics 为 %d", rec.Code)
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	metrics := newTestMetrics(t)
+	logger := zerolog.Nop()
+	router := NewRouter(NewRouterDi{Logger: &logger, Metrics: metrics})
+	router.GET("/users/:id", func(ctx HttpContext) error {
+		return ctx.NoContent(http.StatusNoContent)
+	})
+	router.GET("/fail", func(ctx HttpContext) error {
+		return echo.NewHTTPError(http.StatusConflict)
+	})
+	handler := router.GetHandler()
+	for _, path := range []string{"/users/1", "/users/2", "/fail"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// 按路由模板统计，错误取最终状态码。
+	if got := testutil.ToFloat64(metrics.httpRequests.WithLabelValues(http.MethodGet, "/users/:id", "204")); got != 2 {
+		t.Errorf("/users/:id 请求数为 %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.httpRequests.WithLabelValues(http.MethodGet, "/fail", "409")); got != 1 {
+		t.Errorf("/fail 请求数为 %v", got)
+	}
+	if got := testutil.CollectAndCount(metrics.httpDuration); got != 2 {
+		t.Errorf("耗时序列数为 %d", got)
+	}
+
+	// 通过 promhttp 输出。
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	if rec.Code != http.StatusOK || !strings.Contains(string(body), `cjungo_http_requests_total{method="GET",route="/users/:id",status="204"} 2`) {
+		t.Fatalf("/metrics 为 %d %s", rec.Code, body)
+	}
+}
+
+func TestMetricsMiddlewareReturnError(t *testing.T) {
+	metrics := newTestMetrics(t)
+	output := &bytes.Buffer{}
+	logger := zerolog.New(output)
+	router := NewRouter(NewRouterDi{Logger: &logger, Metrics: metrics})
+	router.GET("/fail", func(ctx HttpContext) error {
+		return ErrConflict(errors.New("冲突"))
+	})
+	rec := httptest.NewRecorder()
+	router.GetHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	// 错误只处理一次，响应体只有一个 JSON 。
+	result := &ApiError{}
+	if err := json.Unmarshal(rec.Body.Bytes(), result); err != nil || rec.Code != http.StatusConflict || result.Code != ERROR_CODE_CONFLICT {
+		t.Fatalf("响应为 %d %s %v", rec.Code, rec.Body, err)
+	}
+	if got := testutil.ToFloat64(metrics.httpRequests.WithLabelValues(http.MethodGet, "/fail", "409")); got != 1 {
+		t.Errorf("/fail 请求数为 %v", got)
+	}
+	// 错误继续返回给外层，访问日志记录了错误。
+	if !strings.Contains(output.String(), `"error":"{\"code\":40900`) {
+		t.Errorf("访问日志为 %s", output)
+	}
+}
+
+func TestMetricsObserveDbQuery(t *testing.T) {
+	metrics := newTestMetrics(t)
+	metrics.ObserveDbQuery("mysql", "SELECT * FROM users", time.Millisecond, nil)
+	metrics.ObserveDbQuery("mysql", "  insert into users values (1)", time.Millisecond, nil)
+	metrics.ObserveDbQuery("mysql", "PRAGMA foreign_keys", time.Millisecond, errors.New("x"))
+	metrics.ObserveDbQuery("mysql", "", time.Millisecond, nil)
+
+	// operation 取第一个关键字，不认识的记为 other 。
+	for _, labels := range [][]string{
+		{"mysql", "select", "ok"},
+		{"mysql", "insert", "ok"},
+		{"mysql", "other", "error"},
+		{"mysql", "other", "ok"},
+	} {
+		histogram := metrics.dbDuration.WithLabelValues(labels...).(prometheus.Histogram)
+		if got := testutil.CollectAndCount(histogram); got != 1 {
+			t.Errorf("%v 序列数为 %d", labels, got)
+		}
+	}
+	if got := testutil.CollectAndCount(metrics.dbDuration); got != 4 {
+		t.Errorf("序列数为 %d", got)
+	}
+}
+
+func TestMetricsTaskQueue(t *testing.T) {
+	metrics := newTestMetrics(t)
+	queue := newTestTaskQueue(t, TaskQueueDi{Metrics: metrics})
+	queue.RegisterProcess("ok", func(action *TaskAction) (TaskResultMessage, error) {
+		return nil, nil
+	})
+	queue.RegisterProcess("fail", func(action *TaskAction) (TaskResultMessage, error) {
+		return nil, errors.New("失败")
+	})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, queue)
+	waitTaskStatus(t, queue, pushTestTask(t, queue, "ok"), TASK_STATUS_OK)
+	pushTestTask(t, queue, "fail")
+
+	waitMetricValue(t, metrics.taskFailures.WithLabelValues("fail"), 1)
+	if got := testutil.ToFloat64(metrics.taskFailures.WithLabelValues("ok")); got != 0 {
+		t.Errorf("ok 失败数为 %v", got)
+	}
+
+	// 积压数和容量。
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{"cjungo_task_queue_depth 0", "cjungo_task_queue_capacity 64"} {
+		if !strings.Contains(body, line) {
+			t.Errorf("指标中没有 %s", line)
+		}
+	}
+}
+
+func TestMetricsStreams(t *testing.T) {
+	metrics := newTestMetrics(t)
+	logger := zerolog.Nop()
+	router := NewRouter(NewRouterDi{Logger: &logger, Metrics: metrics})
+	started := make(chan struct{})
+	gate := make(chan struct{})
+	router.SSE("/events", func(ctx HttpContext, tx chan SseEvent, rx chan error) {
+		close(started)
+		<-gate
+	})
+	server := httptest.NewServer(router.GetHandler())
+	defer server.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err := http.Get(server.URL + "/events")
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}()
+	<-started
+	gauge := metrics.streams.WithLabelValues(METRICS_STREAM_SSE)
+	waitMetricValue(t, gauge, 1)
+	close(gate)
+	<-done
+	waitMetricValue(t, gauge, 0)
+
+	untrack := metrics.TrackMessageClient()
+	waitMetricValue(t, metrics.messageClients, 1)
+	untrack()
+	waitMetricValue(t, metrics.messageClients, 0)
+}
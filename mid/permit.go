@@ -1,6 +1,7 @@
 package mid
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/cjungo/cjungo"
@@ -66,7 +67,7 @@ func (manager *PermitManager[TP, TS]) Permit(permissions ...TP) echo.MiddlewareF
 				if permit(pp.(PermitProof[TP, TS]), permissions...) {
 					return next(ctx)
 				}
-				return ctx.RespBadF("缺少权限: %v", permissions)
+				return cjungo.ErrForbidden(nil).WithMessage(fmt.Sprintf("缺少权限: %v", permissions))
 			}
 
 			if pp, err := manager.handle(ctx); err != nil {
@@ -83,7 +84,7 @@ func (manager *PermitManager[TP, TS]) Permit(permissions ...TP) echo.MiddlewareF
 				if permit(pp, permissions...) {
 					return next(ctx)
 				}
-				return ctx.RespBadF("缺少权限: %v", permissions)
+				return cjungo.ErrForbidden(nil).WithMessage(fmt.Sprintf("缺少权限: %v", permissions))
 			}
 		}
 	}
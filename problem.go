@@ -0,0 +1,84 @@
+package cjungo
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const MIME_PROBLEM_JSON = "application/problem+json"
+
+// RFC 7807 错误格式，Extensions 与标准字段平铺输出。
+type ProblemDetails struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]any
+}
+
+func (problem *ProblemDetails) MarshalJSON() ([]byte, error) {
+	result := make(map[string]any, len(problem.Extensions)+5)
+	for k, v := range problem.Extensions {
+		result[k] = v
+	}
+	result["type"] = problem.Type
+	result["title"] = problem.Title
+	result["status"] = problem.Status
+	if len(problem.Detail) > 0 {
+		result["detail"] = problem.Detail
+	}
+	if len(problem.Instance) > 0 {
+		result["instance"] = problem.Instance
+	}
+	return json.Marshal(result)
+}
+
+// 把 ApiError 转为 RFC 7807 格式，instance 为请求 ID 。
+// typeBase 为空或错误码没有名称时 type 为 about:blank ，title 为 HTTP 状态描述。
+func NewProblemDetails(err *ApiError, typeBase string) *ProblemDetails {
+	problem := &ProblemDetails{
+		Type:     "about:blank",
+		Title:    http.StatusText(err.HttpCode),
+		Status:   err.HttpCode,
+		Instance: err.ReqID,
+		Extensions: map[string]any{
+			"code": err.Code,
+		},
+	}
+	if len(typeBase) > 0 && len(err.Name) > 0 {
+		problem.Type = typeBase + strings.ToLower(strings.ReplaceAll(err.Name, "_", "-"))
+		if item, ok := GetErrorCode(err.Code); ok {
+			problem.Title = item.Message
+		}
+	}
+	if message, ok := err.Message.(string); ok {
+		problem.Detail = message
+	} else if err.Message != nil {
+		problem.Extensions["message"] = err.Message
+	}
+	if len(err.Name) > 0 {
+		problem.Extensions["name"] = err.Name
+	}
+	if len(err.I18nKey) > 0 {
+		problem.Extensions["i18nKey"] = err.I18nKey
+	}
+	if len(err.Details) > 0 {
+		problem.Extensions["details"] = err.Details
+	}
+	return problem
+}
+
+// Accept 中是否有 application/problem+json 。
+func isAcceptProblemJson(req *http.Request) bool {
+	for _, accept := range req.Header.Values("Accept") {
+		for _, item := range strings.Split(accept, ",") {
+			if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(item)); err == nil && mediaType == MIME_PROBLEM_JSON {
+				return true
+			}
+		}
+	}
+	return false
+}
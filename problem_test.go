@@ -0,0 +1,128 @@
+package cjungo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+)
+
+func newTestProblemRouter(t *testing.T, conf *HttpServerConf) func(path string, accept string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	logger := zerolog.Nop()
+	router := NewRouter(NewRouterDi{Logger: &logger, Conf: conf})
+	router.GET("/validation", func(ctx HttpContext) error {
+		return ErrValidation(ErrorDetail{Field: "name", Message: "不能为空"})
+	})
+	router.GET("/not-found", func(ctx HttpContext) error {
+		return ErrNotFound(errors.New("x"))
+	})
+	return func(path string, accept string) (*httptest.ResponseRecorder, map[string]any) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if len(accept) > 0 {
+			req.Header.Set("Accept", accept)
+		}
+		router.GetHandler().ServeHTTP(rec, req)
+		body := map[string]any{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return rec, body
+	}
+}
+
+func TestProblemJsonDefault(t *testing.T) {
+	get := newTestProblemRouter(t, &HttpServerConf{})
+
+	// 没有配置也没有 Accept 时仍是原来的格式。
+	rec, body := get("/validation", "application/json")
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != echo.MIMEApplicationJSON {
+		t.Fatalf("响应为 %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if body["code"] != float64(ERROR_CODE_VALIDATION) || body["name"] != "VALIDATION" || body["message"] != "参数校验失败" ||
+		body["i18nKey"] != "error.validation" || body["reqId"] != rec.Header().Get("X-Request-ID") || body["details"] == nil {
+		t.Fatalf("错误为 %v", body)
+	}
+	if _, ok := body["type"]; ok {
+		t.Fatalf("错误为 %v", body)
+	}
+}
+
+func TestProblemJsonAccept(t *testing.T) {
+	get := newTestProblemRouter(t, &HttpServerConf{})
+
+	rec, body := get("/validation", "text/html, application/problem+json;q=0.9")
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != MIME_PROBLEM_JSON {
+		t.Fatalf("响应为 %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	// 没有配置 type 前缀时为 about:blank ，title 为 HTTP 状态描述。
+	if body["type"] != "about:blank" || body["title"] != "Bad Request" || body["status"] != float64(http.StatusBadRequest) ||
+		body["detail"] != "参数校验失败" || body["instance"] != rec.Header().Get("X-Request-ID") {
+		t.Fatalf("错误为 %v", body)
+	}
+	details, _ := body["details"].([]any)
+	if body["code"] != float64(ERROR_CODE_VALIDATION) || body["name"] != "VALIDATION" || body["i18nKey"] != "error.validation" || len(details) != 1 {
+		t.Fatalf("扩展字段为 %v", body)
+	}
+	if _, ok := body["reqId"]; ok {
+		t.Fatalf("错误为 %v", body)
+	}
+}
+
+func TestProblemJsonConf(t *testing.T) {
+	typeBase := "https://example.com/errors/"
+	get := newTestProblemRouter(t, &HttpServerConf{IsProblemJson: true, ProblemTypeBase: &typeBase})
+
+	// 开启后不需要 Accept 。
+	rec, body := get("/not-found", "")
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != MIME_PROBLEM_JSON {
+		t.Fatalf("响应为 %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if body["type"] != "https://example.com/errors/not-found" || body["title"] != "资源不存在" || body["status"] != float64(http.StatusNotFound) ||
+		body["instance"] != rec.Header().Get("X-Request-ID") || body["code"] != float64(ERROR_CODE_NOT_FOUND) {
+		t.Fatalf("错误为 %v", body)
+	}
+}
+
+func TestNewProblemDetails(t *testing.T) {
+	// 非字符串的 message 放入扩展字段。
+	err := NewApiError(ERROR_CODE_INTERNAL, nil).WithMessage(map[string]any{"retry": true})
+	problem := NewProblemDetails(err, "")
+	if len(problem.Detail) != 0 || problem.Extensions["message"] == nil || problem.Status != http.StatusInternalServerError {
+		t.Fatalf("错误为 %+v", problem)
+	}
+
+	// 错误码没有名称时 type 为 about:blank 。
+	problem = NewProblemDetails(&ApiError{Code: 1, HttpCode: http.StatusTeapot, Message: "茶壶"}, "https://example.com/errors/")
+	if problem.Type != "about:blank" || problem.Title != http.StatusText(http.StatusTeapot) || problem.Detail != "茶壶" {
+		t.Fatalf("错误为 %+v", problem)
+	}
+}
+
+func TestIsAcceptProblemJson(t *testing.T) {
+	tests := []struct {
+		accept []string
+		want   bool
+	}{
+		{nil, false},
+		{[]string{"application/json"}, false},
+		{[]string{"application/problem+json"}, true},
+		{[]string{"application/json, application/problem+json; q=0.5"}, true},
+		{[]string{"text/html", "application/problem+json"}, true},
+		{[]string{"application/problem+xml"}, false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, accept := range test.accept {
+			req.Header.Add("Accept", accept)
+		}
+		if got := isAcceptProblemJson(req); got != test.want {
+			t.Errorf("%v 为 %v", test.accept, got)
+		}
+	}
+}
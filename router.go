@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/fs"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -59,6 +60,7 @@ type HttpRouter interface {
 type HttpSimpleRouter struct {
 	subject *echo.Echo
 	logger  *zerolog.Logger
+	metrics *Metrics
 }
 
 func wrapContext(h HttpHandlerFunc) echo.HandlerFunc {
@@ -68,11 +70,15 @@ func wrapContext(h HttpHandlerFunc) echo.HandlerFunc {
 	}
 }
 
-func wrapLongPolling(logger *zerolog.Logger, h LongPollingHandlerFunc) echo.HandlerFunc {
+func wrapLongPolling(metrics *Metrics, h LongPollingHandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		ctx := c.(HttpContext)
+		logger := ctx.GetLogger()
+		defer metrics.trackStream(METRICS_STREAM_LONG_POLLING)()
 
-		reqId := ctx.GetReqID()
+		if err := DisableStreamTimeout(ctx); err != nil {
+			return err
+		}
 
 		response := ctx.Response()
 		response.Header().Set("Content-Type", "application/octet-stream")
@@ -80,7 +86,6 @@ func wrapLongPolling(logger *zerolog.Logger, h LongPollingHandlerFunc) echo.Hand
 		response.Header().Set("Connection", "keep-alive")
 		logger.Info().
 			Str("action", "start").
-			Str("reqId", reqId).
 			Msg("[LONG POLLING]")
 
 		tx := make(chan LongPollingEvent)
@@ -96,7 +101,6 @@ func wrapLongPolling(logger *zerolog.Logger, h LongPollingHandlerFunc) echo.Hand
 			case <-ctx.Request().Context().Done():
 				logger.Info().
 					Str("action", "done").
-					Str("reqId", reqId).
 					Msg("[LONG POLLING]")
 				return nil
 			case msg, ok := <-tx:
@@ -107,7 +111,6 @@ func wrapLongPolling(logger *zerolog.Logger, h LongPollingHandlerFunc) echo.Hand
 				logger.Info().
 					Str("action", "tx").
 					Any("msg", msg).
-					Str("reqId", reqId).
 					Msg("[LONG POLLING]")
 
 				// 错误
@@ -125,11 +128,15 @@ func wrapLongPolling(logger *zerolog.Logger, h LongPollingHandlerFunc) echo.Hand
 	}
 }
 
-func wrapSse(logger *zerolog.Logger, h SseHandlerFunc) echo.HandlerFunc {
+func wrapSse(metrics *Metrics, h SseHandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		ctx := c.(HttpContext)
+		logger := ctx.GetLogger()
+		defer metrics.trackStream(METRICS_STREAM_SSE)()
 
-		reqId := ctx.GetReqID()
+		if err := DisableStreamTimeout(ctx); err != nil {
+			return err
+		}
 
 		response := ctx.Response()
 		response.Header().Set("Content-Type", "text/event-stream")
@@ -138,7 +145,6 @@ func wrapSse(logger *zerolog.Logger, h SseHandlerFunc) echo.HandlerFunc {
 
 		logger.Info().
 			Str("action", "start").
-			Str("reqId", reqId).
 			Msg("[SSE]")
 
 		tx := make(chan SseEvent)
@@ -153,7 +159,6 @@ func wrapSse(logger *zerolog.Logger, h SseHandlerFunc) echo.HandlerFunc {
 			case <-ctx.Request().Context().Done():
 				logger.Info().
 					Str("action", "done").
-					Str("reqId", reqId).
 					Msg("[SSE]")
 				return nil
 			case msg, ok := <-tx:
@@ -164,7 +169,6 @@ func wrapSse(logger *zerolog.Logger, h SseHandlerFunc) echo.HandlerFunc {
 				logger.Info().
 					Str("action", "tx").
 					Any("msg", msg).
-					Str("reqId", reqId).
 					Msg("[SSE]")
 
 				// 错误
@@ -217,11 +221,11 @@ func (router *HttpSimpleRouter) GET(path string, h HttpHandlerFunc, m ...echo.Mi
 }
 
 func (router *HttpSimpleRouter) SSE(path string, h SseHandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
-	return router.subject.GET(path, wrapSse(router.logger, h), m...)
+	return router.subject.GET(path, wrapSse(router.metrics, h), m...)
 }
 
 func (router *HttpSimpleRouter) LongPolling(path string, h LongPollingHandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
-	return router.subject.GET(path, wrapLongPolling(router.logger, h), m...)
+	return router.subject.GET(path, wrapLongPolling(router.metrics, h), m...)
 }
 
 func (router *HttpSimpleRouter) PUT(path string, h HttpHandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
@@ -244,6 +248,7 @@ func (router *HttpSimpleRouter) Group(prefix string, m ...echo.MiddlewareFunc) (
 	return &HttpSimpleGroup{
 		subject: router.subject.Group(prefix, m...),
 		logger:  router.logger,
+		metrics: router.metrics,
 	}
 }
 
@@ -265,6 +270,7 @@ func (router *HttpSimpleRouter) StaticFS(pathPrefix string, filesystem fs.FS) *e
 type HttpSimpleGroup struct {
 	subject *echo.Group
 	logger  *zerolog.Logger
+	metrics *Metrics
 }
 
 func (group *HttpSimpleGroup) Any(path string, h HttpHandlerFunc, m ...echo.MiddlewareFunc) []*echo.Route {
@@ -276,11 +282,11 @@ func (group *HttpSimpleGroup) GET(path string, h HttpHandlerFunc, m ...echo.Midd
 }
 
 func (group *HttpSimpleGroup) SSE(path string, h SseHandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
-	return group.subject.GET(path, wrapSse(group.logger, h), m...)
+	return group.subject.GET(path, wrapSse(group.metrics, h), m...)
 }
 
 func (group *HttpSimpleGroup) LongPolling(path string, h LongPollingHandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
-	return group.subject.GET(path, wrapLongPolling(group.logger, h), m...)
+	return group.subject.GET(path, wrapLongPolling(group.metrics, h), m...)
 }
 
 func (group *HttpSimpleGroup) PUT(path string, h HttpHandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
@@ -299,6 +305,7 @@ func (group *HttpSimpleGroup) Group(prefix string, m ...echo.MiddlewareFunc) (g
 	return &HttpSimpleGroup{
 		subject: group.subject.Group(prefix, m...),
 		logger:  group.logger,
+		metrics: group.metrics,
 	}
 }
 
@@ -308,8 +315,14 @@ func (group *HttpSimpleGroup) Use(middleware ...echo.MiddlewareFunc) {
 
 type NewRouterDi struct {
 	dig.In
-	Logger *zerolog.Logger
-	Conf   *HttpServerConf `optional:"true"`
+	Logger  *zerolog.Logger
+	Conf    *HttpServerConf `optional:"true"`
+	Watcher *ConfWatcher    `optional:"true"`
+	Admin   *AdminRouter    `optional:"true"` // 有管理端口时 swagger 、指标挂在管理端口
+	Metrics *Metrics        `optional:"true"`
+	Tracing *Tracing        `optional:"true"`
+	// 自定义请求 ID 生成，优先于 CJUNGO_HTTP_REQ_ID_GENERATOR
+	ReqIDGenerator ReqIDGenerator `optional:"true"`
 }
 
 type RouterLogger struct {
@@ -340,62 +353,141 @@ func NewRouter(di NewRouterDi) HttpRouter {
 	)
 
 	// 使用自定义上下文
-	router.Use(ResetContext)
+	reqIDHeaders := []string{}
+	reqIDResponseHeader := echo.HeaderXRequestID
+	generate := di.ReqIDGenerator
+	if di.Conf != nil {
+		reqIDHeaders = di.Conf.ReqIDHeaders
+		reqIDResponseHeader = GetOrDefault(di.Conf.ReqIDResponseHeader, echo.HeaderXRequestID)
+		if generate == nil {
+			name := GetOrDefault(di.Conf.ReqIDGenerator, "uuid")
+			if g, err := GetReqIDGenerator(name); err != nil {
+				di.Logger.Error().Str("action", "请求 ID 生成方式无效，使用 uuid").Err(err).Msg("[HTTP]")
+			} else {
+				generate = g
+			}
+		}
+	}
+	router.Use(NewResetContext(reqIDHeaders, reqIDResponseHeader, generate))
+
+	// 没有启用链路追踪时也传递上游的追踪上下文。
+	router.Use(di.Tracing.middleware())
+
+	// 请求日志，通过 HttpContext.GetLogger 获取。
+	router.Use(newRequestLoggerMiddleware(di.Logger))
+
+	if di.Metrics != nil {
+		router.Use(di.Metrics.middleware())
+	}
+
+	// 错误只在这里处理一次，外层的指标、链路追踪读取处理后的状态码，
+	// 错误继续返回，访问日志可以记录。
+	router.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := next(c)
+			if err != nil {
+				c.Echo().HTTPErrorHandler(err, c) // c.Error 会传入 echo 内部的上下文
+			}
+			return err
+		}
+	})
+
+	// 请求体大小限制，需在打印请求内容之前。
+	if di.Conf != nil && di.Conf.MaxBodySize != nil {
+		router.Use(middleware.BodyLimit(fmt.Sprintf("%d", *di.Conf.MaxBodySize)))
+	}
 
-	if di.Conf != nil && di.Conf.IsDumpBody {
-		router.Use(NewDumpBodyMiddleware(func(ctx HttpContext, req, resp []byte) error {
-			di.Logger.Info().
-				Str("reqId", ctx.GetReqID()).
+	// 有配置监视时，可以通过 CJUNGO_HTTP_IS_DUMP_BODY 随时开关。
+	isDumpBody := atomic.Bool{}
+	isDumpBody.Store(di.Conf != nil && di.Conf.IsDumpBody)
+	if di.Watcher != nil {
+		if err := WatchConf(di.Watcher, di.Conf, func(change ConfChange[HttpServerConf]) {
+			if change.Old.IsDumpBody != change.New.IsDumpBody {
+				isDumpBody.Store(change.New.IsDumpBody)
+				di.Logger.Info().Bool("isDumpBody", change.New.IsDumpBody).Str("action", "打印请求内容开关变更").Msg("[HTTP]")
+			}
+		}); err != nil {
+			di.Logger.Error().Str("action", "订阅配置变更失败").Err(err).Msg("[HTTP]")
+		}
+	}
+	if isDumpBody.Load() || di.Watcher != nil {
+		dumpBody := NewDumpBodyMiddleware(func(ctx HttpContext, req, resp []byte) error {
+			ctx.GetLogger().Info().
 				Str("url", ctx.Request().RequestURI).
 				Str("body", string(req)).
 				Str("action", "打印请求内容").
 				Msg("[HTTP]")
 
 			// TODO 当启用 GZIP 压缩时，信息在日志中是压缩后的数据
-			di.Logger.Info().
-				Str("reqId", ctx.GetReqID()).
+			ctx.GetLogger().Info().
 				Str("url", ctx.Request().RequestURI).
 				Any("body", string(resp)).
 				Str("action", "打印响应内容").
 				Msg("[HTTP]")
 			return nil
-		}))
+		})
+		router.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			dumped := dumpBody(next)
+			return func(c echo.Context) error {
+				if isDumpBody.Load() {
+					return dumped(c)
+				}
+				return next(c)
+			}
+		})
 	}
 
-	// 错误处理句柄
+	// 错误处理句柄，默认返回 {code, message} ，可以选择 RFC 7807 格式。
+	isProblemJson := di.Conf != nil && di.Conf.IsProblemJson
+	problemTypeBase := ""
+	if di.Conf != nil {
+		problemTypeBase = GetOrDefault(di.Conf.ProblemTypeBase, "")
+	}
 	router.HTTPErrorHandler = func(err error, ctx echo.Context) {
-		var result *ApiError
-		if apiError, ok := err.(*ApiError); ok {
-			result = apiError
-		} else {
-			result = &ApiError{
-				Code:     -1,
-				Message:  err.Error(),
-				HttpCode: http.StatusInternalServerError,
-				Reason:   err,
-			}
-			if httpError, ok := err.(*echo.HTTPError); ok {
-				result.HttpCode = httpError.Code
-			}
+		// 已经处理过或已经开始响应。
+		if ctx.Response().Committed {
+			return
 		}
-
-		di.Logger.Error().
+		result := toApiError(err)
+
+		// 复制一份再带上请求 ID ，ApiError 可能是共享的变量。
+		body := *result
+		logger := di.Logger
+		if hc, ok := ctx.(HttpContext); ok {
+			logger = hc.GetLogger()
+			body.ReqID = hc.GetReqID()
+		}
+		logger.Error().
 			Stack().
 			Int("code", result.HttpCode).
 			Err(err).
 			Msg("[HTTP]")
 
-		ctx.JSON(result.HttpCode, result)
+		if isProblemJson || isAcceptProblemJson(ctx.Request()) {
+			ctx.Response().Header().Set(echo.HeaderContentType, MIME_PROBLEM_JSON)
+			ctx.JSON(result.HttpCode, NewProblemDetails(&body, problemTypeBase))
+			return
+		}
+		ctx.JSON(result.HttpCode, &body)
 	}
 
-	if di.Conf != nil && di.Conf.IsSwag {
-		link := fmt.Sprintf("http://%s:%d/swagger/", *di.Conf.Host, *di.Conf.Port)
+	if di.Conf != nil && di.Conf.IsSwag && di.Admin == nil {
+		scheme := "http"
+		if len(GetOrDefault(di.Conf.TlsCertPath, "")) > 0 {
+			scheme = "https"
+		}
+		link := fmt.Sprintf("%s://%s:%d/swagger/", scheme, GetOrDefault(di.Conf.Host, "127.0.0.1"), GetOrDefault(di.Conf.Port, 12345))
 		router.GET("/swagger/*", echoSwagger.WrapHandler)
 		di.Logger.Info().Str("link", link).Msg("[SWAG]")
 	}
 
-	return &HttpSimpleRouter{
+	simple := &HttpSimpleRouter{
 		subject: router,
 		logger:  di.Logger,
+		metrics: di.Metrics,
+	}
+	if di.Admin == nil {
+		di.Metrics.mount(simple)
 	}
+	return simple
 }
@@ -1,24 +1,50 @@
 package cjungo
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
 	"go.uber.org/dig"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 type HttpServerConf struct {
-	Host           *string
-	Port           *uint16
-	ReadTimeout    *time.Duration
-	WriteTimeout   *time.Duration
-	MaxHeaderBytes *int
-	IsDumpBody     bool
-	IsSwag         bool
+	Host            *string        `env:"CJUNGO_HTTP_HOST" default:"127.0.0.1"`
+	Port            *uint16        `env:"CJUNGO_HTTP_PORT" default:"12345" min:"1"`
+	ReadTimeout     *time.Duration `env:"CJUNGO_HTTP_READ_TIMEOUT" default:"10s" min:"0s"`
+	WriteTimeout    *time.Duration `env:"CJUNGO_HTTP_WRITE_TIMEOUT" default:"10s" min:"0s"`
+	MaxHeaderBytes  *int           `env:"CJUNGO_HTTP_MAX_HEADER_BYTES" default:"1000000" min:"1"`
+	ShutdownTimeout *time.Duration `env:"CJUNGO_HTTP_SHUTDOWN_TIMEOUT" default:"10s" min:"0s"`
+
+	ReadHeaderTimeout *time.Duration `env:"CJUNGO_HTTP_READ_HEADER_TIMEOUT" min:"0s"` // 为空时同 ReadTimeout
+	IdleTimeout       *time.Duration `env:"CJUNGO_HTTP_IDLE_TIMEOUT" min:"0s"`        // 为空时同 ReadTimeout
+	MaxBodySize       *ByteSize      `env:"CJUNGO_HTTP_MAX_BODY_SIZE" min:"1"`        // 如 10MB ，为空时不限制
+	IsKeepAlive       *bool          `env:"CJUNGO_HTTP_IS_KEEP_ALIVE" default:"true"`
+	IsH2c             bool           `env:"CJUNGO_HTTP_IS_H2C"`  // 未启用 HTTPS 时支持明文 HTTP/2
+	Listens           []string       `env:"CJUNGO_HTTP_LISTENS"` // 如 tcp://0.0.0.0:8080,unix:///run/app.sock,fd://3 ，为空时监听 Host:Port
+
+	TlsCertPath     *string  `env:"CJUNGO_HTTP_TLS_CERT_PATH"`                     // 证书文件，配置后启用 HTTPS ，文件更新后自动重新加载
+	TlsKeyPath      *string  `env:"CJUNGO_HTTP_TLS_KEY_PATH"`                      // 私钥文件
+	TlsMinVersion   *string  `env:"CJUNGO_HTTP_TLS_MIN_VERSION" default:"1.2"`     // 1.0 、1.1 、1.2 、1.3
+	TlsCipherSuites []string `env:"CJUNGO_HTTP_TLS_CIPHER_SUITES"`                 // 如 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 ，为空时使用默认值，对 TLS 1.3 无效
+	TlsClientCaPath *string  `env:"CJUNGO_HTTP_TLS_CLIENT_CA_PATH"`                // 配置后校验客户端证书（双向 TLS）
+	TlsClientAuth   *string  `env:"CJUNGO_HTTP_TLS_CLIENT_AUTH" default:"require"` // require 必须提供客户端证书，request 提供时才校验
+	TlsRedirectPort *uint16  `env:"CJUNGO_HTTP_TLS_REDIRECT_PORT" min:"1"`         // 配置后在该端口监听 HTTP 并跳转到 HTTPS
+
+	ReqIDHeaders        []string `env:"CJUNGO_HTTP_REQ_ID_HEADERS"`                                // 信任的上游请求 ID 头，如 X-Request-ID ，为空时总是生成
+	ReqIDResponseHeader *string  `env:"CJUNGO_HTTP_REQ_ID_RESPONSE_HEADER" default:"X-Request-ID"` // 在响应头返回请求 ID
+	ReqIDGenerator      *string  `env:"CJUNGO_HTTP_REQ_ID_GENERATOR" default:"uuid"`               // uuid 、uuidv7 ，提供 ReqIDGenerator 时忽略
+
+	IsProblemJson   bool    `env:"CJUNGO_HTTP_IS_PROBLEM_JSON"`   // 错误总是返回 RFC 7807 格式，否则只在 Accept 为 application/problem+json 时返回
+	ProblemTypeBase *string `env:"CJUNGO_HTTP_PROBLEM_TYPE_BASE"` // 如 https://example.com/errors/ ，type 为其加错误码名称，为空时为 about:blank
+
+	IsDumpBody bool `env:"CJUNGO_HTTP_IS_DUMP_BODY" default:"true"`
+	IsSwag     bool `env:"CJUNGO_HTTP_IS_SWAG" default:"true"`
 }
 
 type NewHttpServerDi struct {
@@ -28,22 +54,45 @@ type NewHttpServerDi struct {
 	Logger  *zerolog.Logger
 }
 
+// 不启用 HTTPS 的服务器，需要 HTTPS 时使用 NewTlsHttpServer 。
 func NewHttpServer(di NewHttpServerDi) *http.Server {
+	server := newHttpServer(&di)
+	if len(GetOrDefault(di.Conf.TlsCertPath, "")) > 0 {
+		di.Logger.Warn().Str("action", "配置了证书，需要使用 NewTlsHttpServer 才能启用 HTTPS").Msg("[HTTP]")
+	}
+	if di.Conf.IsH2c {
+		server.Handler = h2c.NewHandler(di.Handler, &http2.Server{})
+		di.Logger.Info().Str("action", "启用 h2c").Msg("[HTTP]")
+	}
+	return server
+}
+
+// 配置了证书时启用 HTTPS ，否则同 NewHttpServer 。
+func NewTlsHttpServer(di NewHttpServerDi) (*http.Server, error) {
+	if di.Conf == nil {
+		return NewHttpServer(di), nil
+	}
+	tlsConfig, err := NewTlsConfig(di.Conf, di.Logger)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		return NewHttpServer(di), nil
+	}
+	server := newHttpServer(&di)
+	server.TLSConfig = tlsConfig
+	di.Logger.Info().Str("action", "启用 HTTPS").Str("cert", *di.Conf.TlsCertPath).Msg("[HTTP]")
+	return server, nil
+}
+
+func newHttpServer(di *NewHttpServerDi) *http.Server {
 	defaultHost := "127.0.0.1"
 	defaultPort := uint16(12345)
 	defaultReadTimeout := 10 * time.Second
 	defaultWriteTimeout := 10 * time.Second
 	defaultMaxHeaderBytes := 1000000
 	if di.Conf == nil {
-		di.Conf = &HttpServerConf{
-			Host:           &defaultHost,
-			Port:           &defaultPort,
-			ReadTimeout:    &defaultReadTimeout,
-			WriteTimeout:   &defaultWriteTimeout,
-			MaxHeaderBytes: &defaultMaxHeaderBytes,
-			IsDumpBody:     true,
-			IsSwag:         true,
-		}
+		di.Conf = NewDefaultConf[HttpServerConf]()
 		di.Logger.Info().Str("action", "服务器使用默认配置").Msg("[HTTP]")
 	} else {
 		di.Logger.Info().Str("action", "服务器加载配置").Msg("[HTTP]")
@@ -51,13 +100,13 @@ func NewHttpServer(di NewHttpServerDi) *http.Server {
 	host := GetOrDefault(di.Conf.Host, defaultHost)
 	port := GetOrDefault(di.Conf.Port, defaultPort)
 	address := fmt.Sprintf("%s:%d", host, port)
-	// TODO 改用中间件
-	// readTimeout := GetOrDefault(di.Conf.ReadTimeout, defaultReadTimeout)
-	// writeTimeout := GetOrDefault(di.Conf.WriteTimeout, defaultWriteTimeout)
+	// SSE 、LongPolling 等长连接通过 DisableStreamTimeout 取消超时。
+	readTimeout := GetOrDefault(di.Conf.ReadTimeout, defaultReadTimeout)
+	writeTimeout := GetOrDefault(di.Conf.WriteTimeout, defaultWriteTimeout)
 	maxHeaderBytes := GetOrDefault(di.Conf.MaxHeaderBytes, defaultMaxHeaderBytes)
 
 	// 输出服务器信息
-	if e := di.Handler.(*echo.Echo); e != nil {
+	if e, ok := di.Handler.(*echo.Echo); ok {
 		for i, r := range e.Routes() {
 			di.Logger.Info().
 				Str("action", "启用路由").
@@ -68,61 +117,38 @@ func NewHttpServer(di NewHttpServerDi) *http.Server {
 				Msg("[HTTP]")
 		}
 	}
-	di.Logger.Info().Str("action", "服务器监听").Str("address", address).Msg("[HTTP]")
-
-	return &http.Server{
-		Addr:    address,
-		Handler: di.Handler,
-		// TODO 改用中间件
-		// ReadTimeout:    readTimeout,
-		// WriteTimeout:   writeTimeout,
-		MaxHeaderBytes: maxHeaderBytes,
-	}
-}
 
-func LoadHttpServerConfFromEnv(logger *zerolog.Logger) (*HttpServerConf, error) {
-	logger.Info().Str("action", "通过环境变量配置服务器").Msg("[HTTP]")
-	conf := &HttpServerConf{
-		IsDumpBody: true,
-		IsSwag:     true,
-	}
-	host := os.Getenv("CJUNGO_HTTP_HOST")
-	if len(host) > 0 {
-		conf.Host = &host
+	server := &http.Server{
+		Addr:              address,
+		Handler:           di.Handler,
+		ReadTimeout:       readTimeout,
+		ReadHeaderTimeout: GetOrDefault(di.Conf.ReadHeaderTimeout, 0),
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       GetOrDefault(di.Conf.IdleTimeout, 0),
+		MaxHeaderBytes:    maxHeaderBytes,
 	}
+	server.SetKeepAlivesEnabled(GetOrDefault(di.Conf.IsKeepAlive, true))
+	return server
+}
 
-	if err := GetEnvInt("CJUNGO_HTTP_PORT", func(v uint16) {
-		conf.Port = &v
-	}); err != nil {
-		return nil, err
-	}
-	if err := GetEnvDuration("CJUNGO_HTTP_READ_TIMEOUT", func(v time.Duration) {
-		conf.ReadTimeout = &v
-	}); err != nil {
-		return nil, err
-	}
-	if err := GetEnvDuration("CJUNGO_HTTP_WRITE_TIMEOUT", func(v time.Duration) {
-		conf.WriteTimeout = &v
-	}); err != nil {
-		return nil, err
-	}
-	if err := GetEnvInt("CJUNGO_HTTP_MAX_HEADER_BYTES", func(v int) {
-		conf.MaxHeaderBytes = &v
-	}); err != nil {
-		return nil, err
+// 取消当前连接的读写超时，用于 SSE 、LongPolling 、websocket 等长连接，
+// 需在超时之前（一般是处理开始时）调用。
+func DisableStreamTimeout(ctx echo.Context) error {
+	controller := http.NewResponseController(ctx.Response())
+	if err := controller.SetReadDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
 	}
-
-	if err := GetEnvBool("CJUNGO_HTTP_IS_DUMP_BODY", func(v bool) {
-		conf.IsDumpBody = v
-	}); err != nil {
-		return nil, err
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
 	}
+	return nil
+}
 
-	if err := GetEnvBool("CJUNGO_HTTP_IS_SWAG", func(v bool) {
-		conf.IsSwag = v
-	}); err != nil {
+func LoadHttpServerConfFromEnv(logger *zerolog.Logger) (*HttpServerConf, error) {
+	logger.Info().Str("action", "通过环境变量配置服务器").Msg("[HTTP]")
+	conf := &HttpServerConf{}
+	if err := LoadConf(conf); err != nil {
 		return nil, err
 	}
-
 	return conf, nil
 }
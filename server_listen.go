@@ -0,0 +1,113 @@
+package cjungo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 按地址监听，地址格式：
+//
+//	tcp://0.0.0.0:8080 或 0.0.0.0:8080
+//	unix:///run/app.sock  Unix 域套接字，启动时删除残留的套接字文件
+//	fd://3                继承的文件描述符
+//	systemd://            systemd socket 激活传入的所有文件描述符（LISTEN_FDS）
+func ListenHttp(address string) ([]net.Listener, error) {
+	scheme, target, ok := strings.Cut(address, "://")
+	if !ok {
+		scheme, target = "tcp", address
+	}
+	switch scheme {
+	case "tcp", "tcp4", "tcp6":
+		listener, err := net.Listen(scheme, target)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{listener}, nil
+	case "unix":
+		if stat, err := os.Stat(target); err == nil && stat.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(target); err != nil {
+				return nil, err
+			}
+		}
+		listener, err := net.Listen("unix", target)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{listener}, nil
+	case "fd":
+		fd, err := strconv.Atoi(target)
+		if err != nil {
+			return nil, fmt.Errorf("文件描述符 %s 无效", target)
+		}
+		listener, err := listenFd(fd)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{listener}, nil
+	case "systemd":
+		return listenSystemd()
+	default:
+		return nil, fmt.Errorf("不支持的监听地址 %s", address)
+	}
+}
+
+func listenFd(fd int) (net.Listener, error) {
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+	if file == nil {
+		return nil, fmt.Errorf("文件描述符 %d 无效", fd)
+	}
+	defer file.Close() // FileListener 会复制描述符
+	return net.FileListener(file)
+}
+
+// systemd 传入的描述符从 3 开始，共 LISTEN_FDS 个。
+func listenSystemd() ([]net.Listener, error) {
+	if pid := os.Getenv("LISTEN_PID"); len(pid) > 0 && pid != strconv.Itoa(os.Getpid()) {
+		return nil, fmt.Errorf("LISTEN_PID %s 不是当前进程", pid)
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("没有 systemd 传入的文件描述符")
+	}
+	listeners := []net.Listener{}
+	for fd := 3; fd < 3+count; fd++ {
+		listener, err := listenFd(fd)
+		if err != nil {
+			return nil, errors.Join(err, closeListeners(listeners))
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// 服务器的所有监听，没有配置 Listens 时监听 server.Addr 。
+func NewHttpListeners(server *http.Server, conf *HttpServerConf) ([]net.Listener, error) {
+	addresses := []string{server.Addr}
+	if conf != nil && len(conf.Listens) > 0 {
+		addresses = conf.Listens
+	}
+	listeners := []net.Listener{}
+	for _, address := range addresses {
+		items, err := ListenHttp(address)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("监听 %s 失败: %w", address, err), closeListeners(listeners))
+		}
+		listeners = append(listeners, items...)
+	}
+	return listeners, nil
+}
+
+func closeListeners(listeners []net.Listener) error {
+	errs := []error{}
+	for _, listener := range listeners {
+		if err := listener.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,112 @@
+package cjungo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestListenHttpScheme(t *testing.T) {
+	for _, address := range []string{"127.0.0.1:0", "tcp://127.0.0.1:0", "tcp4://127.0.0.1:0"} {
+		listeners, err := ListenHttp(address)
+		if err != nil {
+			t.Fatalf("%s: %v", address, err)
+		}
+		if len(listeners) != 1 || listeners[0].Addr().Network() != "tcp" {
+			t.Errorf("%s 监听为 %v", address, listeners)
+		}
+		closeListeners(listeners)
+	}
+
+	// 继承的文件描述符，ListenHttp 会关闭传入的描述符。
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	file, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listeners, err := ListenHttp(fmt.Sprintf("fd://%d", fd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listeners[0].Addr().String() != tcp.Addr().String() {
+		t.Errorf("监听 %s，应为 %s", listeners[0].Addr(), tcp.Addr())
+	}
+	closeListeners(listeners)
+
+	t.Setenv("LISTEN_FDS", "")
+	t.Setenv("LISTEN_PID", "")
+	for _, address := range []string{"fd://abc", "udp://127.0.0.1:0", "systemd://"} {
+		if _, err := ListenHttp(address); err == nil {
+			t.Errorf("%s 应失败", address)
+		}
+	}
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_PID", strconv.Itoa(syscall.Getpid()+1))
+	if _, err := ListenHttp("systemd://"); err == nil {
+		t.Error("LISTEN_PID 不是当前进程应失败")
+	}
+}
+
+func TestListenHttpUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+
+	// 残留的套接字文件。
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listeners, err := NewHttpListeners(&http.Server{}, &HttpServerConf{Listens: []string{"unix://" + path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "ok")
+		}),
+	}
+	go server.Serve(listeners[0])
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://unix/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != "ok" {
+		t.Fatalf("响应为 %q %v", body, err)
+	}
+
+	// 其中一个失败时关闭已经创建的监听。
+	other := filepath.Join(t.TempDir(), "other.sock")
+	if _, err := NewHttpListeners(&http.Server{}, &HttpServerConf{Listens: []string{"unix://" + other, "ftp://x"}}); err == nil {
+		t.Fatal("应失败")
+	}
+	if conn, err := net.Dial("unix", other); err == nil {
+		conn.Close()
+		t.Error("失败后应关闭已经创建的监听")
+	}
+}
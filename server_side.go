@@ -0,0 +1,61 @@
+package cjungo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// 与主服务器一起启动的附属服务器，如 HTTPS 跳转、管理端口，作为生命周期组件随应用启动和停止。
+type HttpSideServer struct {
+	name      string
+	logger    *zerolog.Logger
+	server    *http.Server
+	addresses []string
+}
+
+// addresses 格式同 ListenHttp 。
+func NewHttpSideServer(name string, logger *zerolog.Logger, server *http.Server, addresses ...string) *HttpSideServer {
+	return &HttpSideServer{
+		name:      name,
+		logger:    logger,
+		server:    server,
+		addresses: addresses,
+	}
+}
+
+func (side *HttpSideServer) Name() string {
+	return side.name
+}
+
+func (side *HttpSideServer) Start(ctx context.Context) error {
+	listeners := []net.Listener{}
+	for _, address := range side.addresses {
+		items, err := ListenHttp(address)
+		if err != nil {
+			return errors.Join(err, closeListeners(listeners))
+		}
+		listeners = append(listeners, items...)
+	}
+	for _, listener := range listeners {
+		side.logger.Info().
+			Str("action", "服务器监听").
+			Str("name", side.name).
+			Str("network", listener.Addr().Network()).
+			Str("address", listener.Addr().String()).
+			Msg("[HTTP]")
+		go func(listener net.Listener) {
+			if err := side.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				side.logger.Error().Str("action", "服务器出错").Str("name", side.name).Err(err).Msg("[HTTP]")
+			}
+		}(listener)
+	}
+	return nil
+}
+
+func (side *HttpSideServer) Stop(ctx context.Context) error {
+	return side.server.Shutdown(ctx)
+}
@@ -0,0 +1,141 @@
+package cjungo
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// 用 NewHttpServer 启动服务，返回地址。
+func startTestHttpServer(t *testing.T, conf *HttpServerConf, handler http.Handler) string {
+	t.Helper()
+	logger := zerolog.Nop()
+	server := NewHttpServer(NewHttpServerDi{Conf: conf, Handler: handler, Logger: &logger})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.ErrorLog = log.New(io.Discard, "", 0)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return "http://" + listener.Addr().String()
+}
+
+func TestNewHttpServerTimeouts(t *testing.T) {
+	logger := zerolog.Nop()
+	read, write, header, idle := 3*time.Second, 4*time.Second, time.Second, 5*time.Second
+	server := NewHttpServer(NewHttpServerDi{
+		Conf: &HttpServerConf{
+			ReadTimeout:       &read,
+			WriteTimeout:      &write,
+			ReadHeaderTimeout: &header,
+			IdleTimeout:       &idle,
+		},
+		Handler: http.NotFoundHandler(),
+		Logger:  &logger,
+	})
+	if server.ReadTimeout != read || server.WriteTimeout != write || server.ReadHeaderTimeout != header || server.IdleTimeout != idle {
+		t.Fatalf("超时为 %v %v %v %v", server.ReadTimeout, server.WriteTimeout, server.ReadHeaderTimeout, server.IdleTimeout)
+	}
+
+	// 为空时 net/http 按 ReadTimeout 处理。
+	server = NewHttpServer(NewHttpServerDi{Conf: &HttpServerConf{ReadTimeout: &read}, Handler: http.NotFoundHandler(), Logger: &logger})
+	if server.ReadHeaderTimeout != 0 || server.IdleTimeout != 0 || server.WriteTimeout != 10*time.Second {
+		t.Fatalf("默认超时为 %v %v %v", server.ReadHeaderTimeout, server.IdleTimeout, server.WriteTimeout)
+	}
+}
+
+func TestDisableStreamTimeout(t *testing.T) {
+	logger := zerolog.Nop()
+	router := NewRouter(NewRouterDi{Logger: &logger})
+	wait := 300 * time.Millisecond
+	router.SSE("/events", func(ctx HttpContext, tx chan SseEvent, rx chan error) {
+		tx <- SseEvent{Event: "tick", Data: 1}
+		time.Sleep(wait)
+		tx <- SseEvent{Event: "tick", Data: 2}
+	})
+	router.LongPolling("/poll", func(ctx HttpContext, tx chan LongPollingEvent, rx chan error) {
+		tx <- LongPollingEvent{Data: []byte("first;")}
+		time.Sleep(wait)
+		tx <- LongPollingEvent{Data: []byte("second;")}
+	})
+	router.GET("/plain", func(ctx HttpContext) error {
+		ctx.Response().Write([]byte("first;"))
+		ctx.Response().Flush()
+		time.Sleep(wait)
+		ctx.Response().Write([]byte("second;"))
+		return nil
+	})
+	writeTimeout := 100 * time.Millisecond
+	address := startTestHttpServer(t, &HttpServerConf{WriteTimeout: &writeTimeout}, router.GetHandler())
+	get := func(path string) string {
+		resp, err := http.Get(address + path)
+		if err != nil {
+			return ""
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	// 长连接超过 WriteTimeout 后仍可写入。
+	if body := get("/events"); !strings.Contains(body, "data: 2") {
+		t.Errorf("SSE 为 %q", body)
+	}
+	if body := get("/poll"); body != "first;second;" {
+		t.Errorf("LongPolling 为 %q", body)
+	}
+	// 普通请求超过 WriteTimeout 后写入失败。
+	if body := get("/plain"); strings.Contains(body, "second;") {
+		t.Errorf("普通请求为 %q", body)
+	}
+}
+
+func TestRouterBodyLimit(t *testing.T) {
+	logger := zerolog.Nop()
+	size := ByteSize(16)
+	router := NewRouter(NewRouterDi{Conf: &HttpServerConf{MaxBodySize: &size}, Logger: &logger})
+	router.POST("/upload", func(ctx HttpContext) error {
+		body, err := io.ReadAll(ctx.Request().Body)
+		if err != nil {
+			return err
+		}
+		return ctx.Resp(len(body))
+	})
+	post := func(body string) int {
+		rec := httptest.NewRecorder()
+		router.GetHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(body)))
+		return rec.Code
+	}
+	if code := post(strings.Repeat("a", 16)); code != http.StatusOK {
+		t.Errorf("未超过限制返回 %d", code)
+	}
+	if code := post(strings.Repeat("a", 17)); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("超过限制返回 %d", code)
+	}
+}
+
+func TestNewHttpServerKeepAlive(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	for _, isKeepAlive := range []bool{true, false} {
+		address := startTestHttpServer(t, &HttpServerConf{IsKeepAlive: &isKeepAlive}, handler)
+		resp, err := http.Get(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		// 关闭长连接时响应 Connection: close 。
+		if resp.Close == isKeepAlive {
+			t.Errorf("IsKeepAlive 为 %v 时 Close 为 %v", isKeepAlive, resp.Close)
+		}
+	}
+}
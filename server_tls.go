@@ -0,0 +1,178 @@
+package cjungo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// 证书加载，握手时检查文件修改时间（最多每秒一次），证书轮换后自动重新加载。
+type TlsCertLoader struct {
+	logger   *zerolog.Logger
+	certPath string
+	keyPath  string
+	mutex    sync.Mutex
+	cert     *tls.Certificate
+	stamp    time.Time
+	checked  time.Time
+}
+
+func NewTlsCertLoader(logger *zerolog.Logger, certPath string, keyPath string) (*TlsCertLoader, error) {
+	loader := &TlsCertLoader{
+		logger:   logger,
+		certPath: certPath,
+		keyPath:  keyPath,
+	}
+	stamp, err := loader.readStamp()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("加载证书失败: %v", err)
+	}
+	loader.cert = &cert
+	loader.stamp = stamp
+	loader.checked = time.Now()
+	return loader, nil
+}
+
+func (loader *TlsCertLoader) readStamp() (time.Time, error) {
+	stamp := time.Time{}
+	for _, path := range []string{loader.certPath, loader.keyPath} {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return stamp, err
+		}
+		if stat.ModTime().After(stamp) {
+			stamp = stat.ModTime()
+		}
+	}
+	return stamp, nil
+}
+
+func (loader *TlsCertLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	loader.mutex.Lock()
+	defer loader.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(loader.checked) < time.Second {
+		return loader.cert, nil
+	}
+	loader.checked = now
+
+	stamp, err := loader.readStamp()
+	if err != nil || stamp.Equal(loader.stamp) {
+		return loader.cert, nil
+	}
+	// 证书和私钥可能不是同时写入的，失败时沿用旧证书，下次再试。
+	cert, err := tls.LoadX509KeyPair(loader.certPath, loader.keyPath)
+	if err != nil {
+		loader.logger.Error().Str("action", "重新加载证书失败").Err(err).Msg("[HTTP]")
+		return loader.cert, nil
+	}
+	loader.cert = &cert
+	loader.stamp = stamp
+	loader.logger.Info().Str("action", "重新加载证书").Str("cert", loader.certPath).Msg("[HTTP]")
+	return loader.cert, nil
+}
+
+// 根据配置生成 TLS 配置，没有配置证书时返回 nil 。
+func NewTlsConfig(conf *HttpServerConf, logger *zerolog.Logger) (*tls.Config, error) {
+	certPath := GetOrDefault(conf.TlsCertPath, "")
+	keyPath := GetOrDefault(conf.TlsKeyPath, "")
+	if len(certPath) == 0 && len(keyPath) == 0 {
+		return nil, nil
+	}
+	if len(certPath) == 0 || len(keyPath) == 0 {
+		return nil, fmt.Errorf("证书和私钥需要同时配置")
+	}
+
+	loader, err := NewTlsCertLoader(logger, certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	minVersion, ok := tlsVersions[GetOrDefault(conf.TlsMinVersion, "1.2")]
+	if !ok {
+		return nil, fmt.Errorf("TLS 版本 %s 无效", *conf.TlsMinVersion)
+	}
+	config := &tls.Config{
+		GetCertificate: loader.GetCertificate,
+		MinVersion:     minVersion,
+	}
+
+	if len(conf.TlsCipherSuites) > 0 {
+		suites := map[string]uint16{}
+		for _, suite := range tls.CipherSuites() {
+			suites[suite.Name] = suite.ID
+		}
+		for _, name := range conf.TlsCipherSuites {
+			id, ok := suites[name]
+			if !ok {
+				return nil, fmt.Errorf("不支持的加密套件 %s", name)
+			}
+			config.CipherSuites = append(config.CipherSuites, id)
+		}
+	}
+
+	if caPath := GetOrDefault(conf.TlsClientCaPath, ""); len(caPath) > 0 {
+		content, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("客户端 CA 证书 %s 无效", caPath)
+		}
+		config.ClientCAs = pool
+		switch clientAuth := GetOrDefault(conf.TlsClientAuth, "require"); clientAuth {
+		case "require":
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		case "request":
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("客户端证书校验方式 %s 无效", clientAuth)
+		}
+	}
+
+	return config, nil
+}
+
+// HTTP 跳转到 HTTPS 的服务。
+func NewHttpRedirectServer(logger *zerolog.Logger, host string, port uint16, httpsPort uint16) *HttpSideServer {
+	server := &http.Server{
+		Handler:           NewHttpsRedirectHandler(httpsPort),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return NewHttpSideServer("HttpRedirectServer", logger, server, net.JoinHostPort(host, strconv.Itoa(int(port))))
+}
+
+// 跳转到同一主机的 HTTPS 端口，443 端口时省略。
+func NewHttpsRedirectHandler(httpsPort uint16) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(int(httpsPort)))
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
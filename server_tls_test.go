@@ -0,0 +1,303 @@
+package cjungo
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certPath string
+	keyPath  string
+}
+
+// 生成证书写入 dir ，parent 为空时自签名（作为 CA）。
+func newTestCert(t *testing.T, dir string, name string, parent *testCert, isClient bool) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+		if isClient {
+			template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		} else {
+			template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+			template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &testCert{
+		cert:     cert,
+		key:      key,
+		certPath: filepath.Join(dir, name+".crt"),
+		keyPath:  filepath.Join(dir, name+".key"),
+	}
+	writeTestEnvFiles(t, map[string]string{
+		result.certPath: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		result.keyPath:  string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})),
+	})
+	return result
+}
+
+// 用 cert 覆盖 target 的文件，并把修改时间设到之后。
+func replaceTestCert(t *testing.T, target *testCert, cert *testCert, stamp time.Time) {
+	t.Helper()
+	for from, to := range map[string]string{cert.certPath: target.certPath, cert.keyPath: target.keyPath} {
+		content, err := os.ReadFile(from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeTestEnvFiles(t, map[string]string{to: string(content)})
+		if err := os.Chtimes(to, stamp, stamp); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTlsCertLoaderReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, dir, "ca", nil, false)
+	server := newTestCert(t, dir, "server", ca, false)
+	next := newTestCert(t, dir, "next", ca, false)
+	logger := zerolog.Nop()
+
+	loader, err := NewTlsCertLoader(&logger, server.certPath, server.keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := func() []byte {
+		cert, err := loader.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert.Certificate[0]
+	}
+	if !bytes.Equal(leaf(), server.cert.Raw) {
+		t.Fatal("证书有误")
+	}
+
+	// 一秒内不检查文件。
+	replaceTestCert(t, server, next, time.Now().Add(time.Minute))
+	if !bytes.Equal(leaf(), server.cert.Raw) {
+		t.Fatal("一秒内不应重新加载")
+	}
+	loader.checked = time.Now().Add(-2 * time.Second)
+	if !bytes.Equal(leaf(), next.cert.Raw) {
+		t.Fatal("应加载新证书")
+	}
+
+	// 证书和私钥不匹配时沿用旧证书。
+	writeTestEnvFiles(t, map[string]string{server.keyPath: "invalid"})
+	stamp := time.Now().Add(2 * time.Minute)
+	os.Chtimes(server.keyPath, stamp, stamp)
+	loader.checked = time.Now().Add(-2 * time.Second)
+	if !bytes.Equal(leaf(), next.cert.Raw) {
+		t.Fatal("加载失败时应沿用旧证书")
+	}
+
+	if _, err := NewTlsCertLoader(&logger, server.certPath, filepath.Join(dir, "missing.key")); err == nil {
+		t.Fatal("文件不存在应失败")
+	}
+}
+
+// 使用 NewTlsHttpServer 启动 HTTPS 服务，返回地址。
+func startTestTlsServer(t *testing.T, conf *HttpServerConf) string {
+	t.Helper()
+	logger := zerolog.Nop()
+	server, err := NewTlsHttpServer(NewHttpServerDi{
+		Conf: conf,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+		Logger: &logger,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.TLSConfig == nil {
+		t.Fatal("应启用 HTTPS")
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.ErrorLog = log.New(io.Discard, "", 0) // 握手失败是预期的
+	go server.ServeTLS(listener, "", "")
+	t.Cleanup(func() { server.Close() })
+	return "https://" + listener.Addr().String()
+}
+
+func TestNewTlsConfigClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, dir, "ca", nil, false)
+	server := newTestCert(t, dir, "server", ca, false)
+	client := newTestCert(t, dir, "client", ca, true)
+	clientCert, err := tls.LoadX509KeyPair(client.certPath, client.keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	// 总是发送 cert ，不按服务器接受的 CA 筛选。
+	get := func(address string, cert *tls.Certificate) error {
+		config := &tls.Config{RootCAs: pool}
+		if cert != nil {
+			config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return cert, nil
+			}
+		}
+		httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		defer httpClient.CloseIdleConnections()
+		resp, err := httpClient.Get(address)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	newConf := func(clientAuth string) *HttpServerConf {
+		return &HttpServerConf{
+			TlsCertPath:     &server.certPath,
+			TlsKeyPath:      &server.keyPath,
+			TlsClientCaPath: &ca.certPath,
+			TlsClientAuth:   &clientAuth,
+		}
+	}
+
+	// require 必须提供客户端证书。
+	address := startTestTlsServer(t, newConf("require"))
+	if err := get(address, nil); err == nil {
+		t.Error("require 时没有客户端证书应失败")
+	}
+	if err := get(address, &clientCert); err != nil {
+		t.Errorf("require 时提供客户端证书应成功: %v", err)
+	}
+
+	// request 提供时才校验。
+	address = startTestTlsServer(t, newConf("request"))
+	if err := get(address, nil); err != nil {
+		t.Errorf("request 时没有客户端证书应成功: %v", err)
+	}
+	if err := get(address, &clientCert); err != nil {
+		t.Errorf("request 时提供客户端证书应成功: %v", err)
+	}
+	other := newTestCert(t, dir, "other", nil, false)
+	stranger := newTestCert(t, dir, "stranger", other, true)
+	strangerCert, err := tls.LoadX509KeyPair(stranger.certPath, stranger.keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := get(address, &strangerCert); err == nil {
+		t.Error("request 时提供其他 CA 签发的证书应失败")
+	}
+
+	logger := zerolog.Nop()
+	version := "1.4"
+	suite := "TLS_UNKNOWN"
+	empty := ""
+	for name, conf := range map[string]*HttpServerConf{
+		"校验方式无效":   newConf("optional"),
+		"TLS 版本无效": {TlsCertPath: &server.certPath, TlsKeyPath: &server.keyPath, TlsMinVersion: &version},
+		"加密套件无效":   {TlsCertPath: &server.certPath, TlsKeyPath: &server.keyPath, TlsCipherSuites: []string{suite}},
+		"CA 无效":    {TlsCertPath: &server.certPath, TlsKeyPath: &server.keyPath, TlsClientCaPath: &server.keyPath},
+		"缺少私钥":     {TlsCertPath: &server.certPath, TlsKeyPath: &empty},
+	} {
+		if _, err := NewTlsConfig(conf, &logger); err == nil {
+			t.Errorf("%s 应失败", name)
+		}
+	}
+	if config, err := NewTlsConfig(&HttpServerConf{}, &logger); config != nil || err != nil {
+		t.Errorf("没有证书时为 %v %v", config, err)
+	}
+}
+
+func TestNewHttpServerWithoutTls(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, dir, "ca", nil, false)
+	server := newTestCert(t, dir, "server", ca, false)
+	logger := zerolog.Nop()
+	di := NewHttpServerDi{
+		Conf:    &HttpServerConf{TlsCertPath: &server.certPath, TlsKeyPath: &server.keyPath},
+		Handler: http.NotFoundHandler(),
+		Logger:  &logger,
+	}
+
+	// NewHttpServer 不启用 HTTPS 。
+	if NewHttpServer(di).TLSConfig != nil {
+		t.Error("NewHttpServer 不应启用 HTTPS")
+	}
+	di.Conf = nil
+	if plain, err := NewTlsHttpServer(di); err != nil || plain.TLSConfig != nil {
+		t.Errorf("没有配置时为 %v", err)
+	}
+}
+
+func TestHttpsRedirectHandler(t *testing.T) {
+	for _, item := range []struct {
+		port   uint16
+		host   string
+		target string
+	}{
+		{8443, "example.com:8080", "https://example.com:8443/a/b?c=1"},
+		{443, "example.com:8080", "https://example.com/a/b?c=1"},
+		{443, "example.com", "https://example.com/a/b?c=1"},
+		{8443, "[::1]:8080", "https://[::1]:8443/a/b?c=1"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/a/b?c=1", nil)
+		req.Host = item.host
+		rec := httptest.NewRecorder()
+		NewHttpsRedirectHandler(item.port).ServeHTTP(rec, req)
+		if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != item.target {
+			t.Errorf("%s 跳转到 %d %s，应为 %s", item.host, rec.Code, rec.Header().Get("Location"), item.target)
+		}
+	}
+}
@@ -1,11 +1,19 @@
 package cjungo
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"reflect"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/dig"
 )
 
@@ -17,17 +25,47 @@ const (
 	TASK_STATUS_START            TaskStatus = "Start"
 	TASK_STATUS_OK               TaskStatus = "Ok"
 	TASK_STATUS_FAILED           TaskStatus = "Failed"
+	TASK_STATUS_RETRY            TaskStatus = "Retry"
+	TASK_STATUS_DEAD             TaskStatus = "Dead"
+	TASK_STATUS_DELAYED          TaskStatus = "Delayed"
+	TASK_STATUS_CANCELLED        TaskStatus = "Cancelled"
+	TASK_STATUS_TIMEOUT          TaskStatus = "Timeout"
 	TASK_STATUS_NOT_HAVE_PROCESS TaskStatus = "Not have process"
 )
 
+// 未完成的任务状态，启动时重新入队。
+var TASK_UNFINISHED_STATUSES = []TaskStatus{
+	TASK_STATUS_PENDING,
+	TASK_STATUS_START,
+	TASK_STATUS_PROCESSING,
+	TASK_STATUS_RETRY,
+}
+
+func IsTaskUnfinished(status TaskStatus) bool {
+	return isTaskStatusIn(status, TASK_UNFINISHED_STATUSES)
+}
+
 type TaskConfig struct {
+	WorkerCount   *int           `env:"CJUNGO_TASK_WORKER_COUNT" default:"1" min:"1"`
+	QueueCapacity *int           `env:"CJUNGO_TASK_QUEUE_CAPACITY" default:"64" min:"1"`
+	PushTimeout   *time.Duration `env:"CJUNGO_TASK_PUSH_TIMEOUT" min:"0s"`  // 队列满时 PushTask 的等待时间，为 0 时立即返回 ErrTaskQueueFull
+	ProcessLimits map[string]int `env:"CJUNGO_TASK_PROCESS_LIMITS"`         // 每种处理器的最大并发数，格式：name1=2,name2=1
+	ResultTTL     *time.Duration `env:"CJUNGO_TASK_RESULT_TTL" min:"0s"`    // 已结束任务的保留时间，为 0 时不清理
+	HealthBacklog *int           `env:"CJUNGO_TASK_HEALTH_BACKLOG" min:"1"` // 积压任务数达到该值时健康检查失败，为空时为队列容量
 }
 
 type TaskResult struct {
-	ID     string
-	Name   string
-	Status TaskStatus
-	Data   TaskResultMessage
+	ID        string
+	Name      string
+	Status    TaskStatus
+	Data      TaskResultMessage
+	Attempts  int
+	LastError string
+	RunAt     *time.Time // 延迟任务的执行时间
+	Progress  float64    // 进度 0~100
+	Message   string     // 进度信息
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 type TaskResultMessage map[string]any
@@ -38,100 +76,821 @@ type TaskAction struct {
 	ID    string
 	Name  string
 	Param TaskActionParam
+	Trace map[string]string // 推送任务时的追踪上下文，任务的 span 链接到推送任务的请求
+	ctx   context.Context
+	queue *TaskQueue
+}
+
+// 任务执行的上下文，任务被取消、超时或队列关闭时结束。
+func (action *TaskAction) Context() context.Context {
+	if action.ctx == nil {
+		return context.Background()
+	}
+	return action.ctx
+}
+
+// 任务日志，带任务 ID 、名称和 trace ID 。
+func (action *TaskAction) Logger() *zerolog.Logger {
+	fallback := &log.Logger
+	if action.queue != nil {
+		fallback = action.queue.Logger
+	}
+	return GetContextLogger(action.Context(), fallback)
+}
+
+// 更新任务进度，percent 取值 0~100 。
+func (action *TaskAction) Progress(percent float64, message string) error {
+	if action.queue == nil {
+		return nil
+	}
+	_, err := action.queue.updateResult(action.ID, func(r *TaskResult) {
+		r.Progress = Limit(0, 100, percent)
+		r.Message = message
+	})
+	return err
 }
 
+type TaskProcessConf struct {
+	Retry   *TaskRetryPolicy // 为空时不重试，失败即 Failed
+	Timeout time.Duration    // 单次执行超时，为 0 时不限，超时且不重试时为 Timeout
+}
+
+type taskProcess struct {
+	process  TaskActionProcess
+	conf     *TaskProcessConf
+	validate func(param TaskActionParam) error
+}
+
+var (
+	ErrTaskQueueStopped = errors.New("任务队列已停止")
+	ErrTaskQueueFull    = errors.New("任务队列已满")
+	ErrTaskCancelled    = errors.New("任务已取消")
+	ErrTaskTimeout      = errors.New("任务执行超时")
+	ErrTaskParamInvalid = errors.New("任务参数无效")
+	ErrTaskNotFound     = errors.New("没有该 ID 的队列信息")
+)
+
 type TaskQueue struct {
-	Logger      *zerolog.Logger
-	unprocessed chan *TaskAction
-	processes   sync.Map
-	results     sync.Map
+	Logger        *zerolog.Logger
+	workerCount   int
+	workerSeq     int
+	workers       sync.WaitGroup
+	shrink        chan struct{}
+	pushTimeout   time.Duration
+	unprocessed   chan *TaskAction
+	processes     sync.Map
+	processLimits map[string]*taskLimit
+	limitMutex    sync.Mutex
+	store         TaskStore
+	clock         Clock
+	timers        map[string]ClockTimer
+	timerMutex    sync.Mutex
+	schedules     []*taskSchedule
+	resultTTL     time.Duration
+	healthBacklog int
+	metrics       *Metrics
+	tracing       *Tracing
+	purgeTimer    ClockTimer
+	watchers      map[string][]chan *TaskResult
+	watcherMutex  sync.Mutex
+	running       map[string]context.CancelCauseFunc
+	runningMutex  sync.Mutex
+	baseCtx       context.Context
+	baseCancel    context.CancelFunc
+	unfinished    []*TaskAction // 创建队列前已存在的未完成任务
+	delayed       []*TaskResult // 创建队列前已存在的延迟任务
+	mutex         sync.Mutex
+	quit          chan struct{}
+	quitOnce      sync.Once
+	done          chan struct{}
+}
+
+// 处理器的并发限制，slots 为 0 时不限制。
+// 没有空位时任务在 waiting 中排队，不占用工作协程。
+type taskLimit struct {
+	slots   int
+	running int
+	waiting []*TaskAction
 }
 
 type TaskQueueDi struct {
 	dig.In
-	Conf   *TaskConfig `optional:"true"`
-	Logger *zerolog.Logger
+	Conf    *TaskConfig  `optional:"true"`
+	Store   TaskStore    `optional:"true"`
+	Clock   Clock        `optional:"true"`
+	Watcher *ConfWatcher `optional:"true"`
+	Metrics *Metrics     `optional:"true"`
+	Tracing *Tracing     `optional:"true"`
+	Logger  *zerolog.Logger
 }
 type TaskQueueProvide func(di TaskQueueDi) (*TaskQueue, error)
 
 func NewTaskQueueHandle(initialize func(*TaskQueue) error) TaskQueueProvide {
 	return func(di TaskQueueDi) (*TaskQueue, error) {
+		conf := di.Conf // 注入的配置，为空时配置监视使用加载的配置
+		if di.Conf == nil {
+			di.Conf = &TaskConfig{}
+		}
+		workerCount := Max(GetOrDefault(di.Conf.WorkerCount, 1), 1)
+		queueCapacity := Max(GetOrDefault(di.Conf.QueueCapacity, 64), 1)
+		if di.Store == nil {
+			di.Store = NewTaskMemoryStore()
+		}
+		if di.Clock == nil {
+			di.Clock = SystemClock{}
+		}
+		baseCtx, baseCancel := context.WithCancel(context.Background())
 		queue := &TaskQueue{
-			Logger:      di.Logger,
-			unprocessed: make(chan *TaskAction, 1),
-			processes:   sync.Map{},
-			results:     sync.Map{},
+			Logger:        di.Logger,
+			workerCount:   workerCount,
+			shrink:        make(chan struct{}),
+			pushTimeout:   GetOrDefault(di.Conf.PushTimeout, 0),
+			unprocessed:   make(chan *TaskAction, queueCapacity),
+			processes:     sync.Map{},
+			processLimits: map[string]*taskLimit{},
+			store:         di.Store,
+			clock:         di.Clock,
+			timers:        map[string]ClockTimer{},
+			schedules:     []*taskSchedule{},
+			resultTTL:     GetOrDefault(di.Conf.ResultTTL, 0),
+			healthBacklog: GetOrDefault(di.Conf.HealthBacklog, queueCapacity),
+			metrics:       di.Metrics,
+			tracing:       di.Tracing,
+			watchers:      map[string][]chan *TaskResult{},
+			running:       map[string]context.CancelCauseFunc{},
+			baseCtx:       baseCtx,
+			baseCancel:    baseCancel,
+			quit:          make(chan struct{}),
+		}
+		for name, slots := range di.Conf.ProcessLimits {
+			queue.processLimits[name] = &taskLimit{slots: Max(slots, 0)}
 		}
 
-		err := initialize(queue)
+		di.Logger.Info().
+			Str("action", "队列配置").
+			Int("workerCount", workerCount).
+			Int("queueCapacity", queueCapacity).
+			Any("processLimits", di.Conf.ProcessLimits).
+			Msg("[TASK]")
+
+		di.Metrics.watchTaskQueue(queue)
+
+		// 有配置监视时，工作协程数和处理器并发数可以随时调整。
+		if di.Watcher != nil {
+			if err := WatchConf(di.Watcher, conf, func(change ConfChange[TaskConfig]) {
+				if !reflect.DeepEqual(change.Old.WorkerCount, change.New.WorkerCount) {
+					queue.SetWorkerCount(GetOrDefault(change.New.WorkerCount, 1))
+				}
+				if !reflect.DeepEqual(change.Old.ProcessLimits, change.New.ProcessLimits) {
+					queue.SetProcessLimits(change.New.ProcessLimits)
+				}
+			}); err != nil {
+				return nil, err
+			}
+		}
+
+		// 在接收新任务之前取出上次未完成的任务，启动时只恢复这些任务。
+		unfinished, err := queue.store.ListActions(TASK_UNFINISHED_STATUSES...)
+		if err != nil {
+			return nil, err
+		}
+		delayed, err := queue.store.List(TASK_STATUS_DELAYED)
+		if err != nil {
+			return nil, err
+		}
+		queue.unfinished = unfinished
+		queue.delayed = delayed
+
+		err = initialize(queue)
 		return queue, err
 	}
 }
 
 func (queue *TaskQueue) setStatus(action *TaskAction, status TaskStatus) {
-	if r, ok := queue.results.Load(action.ID); ok {
-		r.(*TaskResult).Status = status
-		queue.results.Store(action.ID, r)
-	} else {
-		queue.Logger.Error().Str("name", action.Name).Str("id", action.ID).Str("action", "没有该任务的状态信息").Msg("[TASK]")
+	queue.update(action, func(r *TaskResult) {
+		r.Status = status
+	})
+}
+
+func (queue *TaskQueue) Run() error {
+	queue.mutex.Lock()
+	if queue.done != nil {
+		queue.mutex.Unlock()
+		return fmt.Errorf("任务队列已经启动")
+	}
+	select {
+	case <-queue.quit:
+		queue.mutex.Unlock()
+		return ErrTaskQueueStopped
+	default:
 	}
+	done := make(chan struct{})
+	queue.done = done
+	schedules := append([]*taskSchedule{}, queue.schedules...)
+	unfinished, delayed := queue.unfinished, queue.delayed
+	queue.unfinished, queue.delayed = nil, nil
+	workerCount := queue.workerCount
+	for i := 0; i < workerCount; i++ {
+		queue.spawnWorker()
+	}
+	queue.mutex.Unlock()
+
+	go func() {
+		queue.workers.Wait()
+		close(done)
+		queue.Logger.Info().Str("action", "队列关闭").Msg("[TASK]")
+	}()
+	queue.Logger.Info().Str("action", "队列启动").Int("workerCount", workerCount).Msg("[TASK]")
+
+	// 重新入队
+	if len(unfinished) > 0 {
+		queue.Logger.Info().Str("action", "恢复未完成任务").Int("count", len(unfinished)).Msg("[TASK]")
+		go queue.recover(unfinished)
+	}
+	for _, result := range delayed {
+		action, err := queue.store.QueryAction(result.ID)
+		if err != nil {
+			queue.Logger.Error().Str("name", result.Name).Str("id", result.ID).Str("action", "恢复延迟任务出错").Err(err).Msg("[TASK]")
+			continue
+		}
+		queue.armDelay(action, GetOrDefault(result.RunAt, queue.clock.Now()))
+	}
+	for _, schedule := range schedules {
+		queue.armSchedule(schedule)
+	}
+	queue.armPurge()
+	return nil
 }
 
-func (queue *TaskQueue) setData(action *TaskAction, data TaskResultMessage) {
-	if r, ok := queue.results.Load(action.ID); ok {
-		r.(*TaskResult).Data = data
-		queue.results.Store(action.ID, r)
-	} else {
-		queue.Logger.Error().Str("name", action.Name).Str("id", action.ID).Str("action", "没有该任务的状态信息").Msg("[TASK]")
+// 恢复期间已取消或已执行的任务不再入队。
+func (queue *TaskQueue) recover(actions []*TaskAction) {
+	for _, action := range actions {
+		isUnfinished := false
+		queue.update(action, func(r *TaskResult) {
+			if isUnfinished = IsTaskUnfinished(r.Status); isUnfinished {
+				r.Status = TASK_STATUS_PENDING
+			}
+		})
+		if isUnfinished {
+			queue.enqueue(action)
+		}
 	}
 }
 
-func (queue *TaskQueue) Run() error {
+// 需持有 mutex 。
+func (queue *TaskQueue) spawnWorker() {
+	worker := queue.workerSeq
+	queue.workerSeq++
+	queue.workers.Add(1)
 	go func() {
-		queue.Logger.Info().Str("action", "队列启动").Msg("[TASK]")
+		defer queue.workers.Done()
+		queue.work(worker)
+	}()
+}
 
-		for action := range queue.unprocessed {
+func (queue *TaskQueue) work(worker int) {
+	for {
+		select {
+		case <-queue.quit:
+			return
+		case <-queue.shrink:
+			queue.Logger.Info().Str("action", "工作协程退出").Int("worker", worker).Msg("[TASK]")
+			return
+		case action := <-queue.unprocessed:
+			// 队列已关闭，任务保持 Pending ，下次启动时恢复。
+			select {
+			case <-queue.quit:
+				return
+			default:
+			}
+			// 处理器并发已满时，任务排队等待空位，工作协程继续处理其他任务。
+			if !queue.acquire(action) {
+				continue
+			}
+			for action != nil {
+				queue.process(worker, action)
+				action = queue.release(action.Name)
+			}
+		}
+	}
+}
 
-			process, ok := queue.processes.Load(action.Name)
+// 占用处理器的并发空位，没有空位时任务排队并返回 false 。
+func (queue *TaskQueue) acquire(action *TaskAction) bool {
+	queue.limitMutex.Lock()
+	defer queue.limitMutex.Unlock()
+	limit, ok := queue.processLimits[action.Name]
+	if !ok {
+		limit = &taskLimit{}
+		queue.processLimits[action.Name] = limit
+	}
+	if limit.slots > 0 && limit.running >= limit.slots {
+		limit.waiting = append(limit.waiting, action)
+		return false
+	}
+	limit.running++
+	return true
+}
 
-			if !ok {
-				queue.Logger.Error().Str("name", action.Name).Str("id", action.ID).Str("action", "没有该类型的处理器").Msg("[TASK]")
-				queue.setStatus(action, TASK_STATUS_NOT_HAVE_PROCESS)
-				continue
+// 释放并发空位，返回占用该空位的下一个排队任务。
+// 队列关闭后不再取出，排队的任务保持 Pending ，下次启动时恢复。
+func (queue *TaskQueue) release(name string) *TaskAction {
+	queue.limitMutex.Lock()
+	defer queue.limitMutex.Unlock()
+	limit := queue.processLimits[name]
+	limit.running--
+	select {
+	case <-queue.quit:
+		return nil
+	default:
+	}
+	if len(limit.waiting) == 0 || (limit.slots > 0 && limit.running >= limit.slots) {
+		return nil
+	}
+	action := limit.waiting[0]
+	limit.waiting = limit.waiting[1:]
+	limit.running++
+	return action
+}
+
+// 调整工作协程数，减少时空闲的协程先退出。
+func (queue *TaskQueue) SetWorkerCount(count int) {
+	count = Max(count, 1)
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	diff := count - queue.workerCount
+	if diff == 0 {
+		return
+	}
+	queue.workerCount = count
+	queue.Logger.Info().Str("action", "调整工作协程数").Int("workerCount", count).Msg("[TASK]")
+	if queue.done == nil {
+		return
+	}
+	select {
+	case <-queue.quit:
+		return
+	default:
+	}
+	for ; diff > 0; diff-- {
+		queue.spawnWorker()
+	}
+	for ; diff < 0; diff++ {
+		go func() {
+			select {
+			case queue.shrink <- struct{}{}:
+			case <-queue.quit:
 			}
+		}()
+	}
+}
 
-			queue.setStatus(action, TASK_STATUS_START)
-			data, err := process.(TaskActionProcess)(action)
-			queue.setData(action, data)
-			if err != nil {
-				queue.setStatus(action, TASK_STATUS_FAILED)
-				queue.Logger.Error().
-					Str("action", "任务处理出错").
-					Str("name", action.Name).
-					Str("id", action.ID).
-					Any("result", data).
-					AnErr("error", err).
-					Msg("[TASK]")
+// 调整处理器并发数，对之后开始的任务生效，不在 limits 中的处理器不再限制。
+// 空位增加时，排队的任务重新入队。
+func (queue *TaskQueue) SetProcessLimits(limits map[string]int) {
+	queue.limitMutex.Lock()
+	for name := range limits {
+		if _, ok := queue.processLimits[name]; !ok {
+			queue.processLimits[name] = &taskLimit{}
+		}
+	}
+	released := []*TaskAction{}
+	for name, limit := range queue.processLimits {
+		limit.slots = Max(limits[name], 0)
+		count := len(limit.waiting)
+		if limit.slots > 0 {
+			count = Limit(0, count, limit.slots-limit.running)
+		}
+		released = append(released, limit.waiting[:count]...)
+		limit.waiting = limit.waiting[count:]
+	}
+	queue.limitMutex.Unlock()
+	queue.Logger.Info().Str("action", "调整处理器并发数").Any("processLimits", limits).Msg("[TASK]")
+
+	if len(released) > 0 {
+		go func() {
+			for _, action := range released {
+				queue.enqueue(action)
 			}
-			queue.setStatus(action, TASK_STATUS_OK)
-			queue.Logger.Info().
-				Str("action", "完成任务").
-				Str("name", action.Name).
-				Str("id", action.ID).
-				Any("result", data).
-				Msg("[TASK]")
+		}()
+	}
+}
+
+func (queue *TaskQueue) Start(ctx context.Context) error {
+	return queue.Run()
+}
+
+// 停止接收新任务，并等待正在执行的任务完成，
+// ctx 结束时取消正在执行的任务，这些任务在下次启动时重新执行。
+func (queue *TaskQueue) Stop(ctx context.Context) error {
+	// 与 spawnWorker 互斥，停止后不再增加工作协程。
+	queue.mutex.Lock()
+	queue.quitOnce.Do(func() {
+		close(queue.quit)
+	})
+	done := queue.done
+	for _, schedule := range queue.schedules {
+		if schedule.timer != nil {
+			schedule.timer.Stop()
 		}
+	}
+	if queue.purgeTimer != nil {
+		queue.purgeTimer.Stop()
+	}
+	queue.mutex.Unlock()
 
-		queue.Logger.Info().Str("action", "队列关闭").Msg("[TASK]")
-	}()
+	queue.timerMutex.Lock()
+	for id, timer := range queue.timers {
+		timer.Stop()
+		delete(queue.timers, id)
+	}
+	queue.timerMutex.Unlock()
+
+	queue.limitMutex.Lock()
+	waiting := 0
+	for _, limit := range queue.processLimits {
+		waiting += len(limit.waiting)
+	}
+	queue.limitMutex.Unlock()
+	if waiting > 0 {
+		queue.Logger.Info().Str("action", "排队任务保持未完成，下次启动时恢复").Int("count", waiting).Msg("[TASK]")
+	}
+
+	if done == nil {
+		queue.baseCancel()
+		return nil
+	}
+
+	select {
+	case <-done:
+		queue.baseCancel()
+		return nil
+	case <-ctx.Done():
+		queue.baseCancel()
+		return ctx.Err()
+	}
+}
+
+// 健康检查，积压任务过多或队列已停止时失败。
+func (queue *TaskQueue) CheckHealth(ctx context.Context) error {
+	select {
+	case <-queue.quit:
+		return fmt.Errorf("队列已停止")
+	default:
+	}
+	if backlog := len(queue.unprocessed); backlog >= queue.healthBacklog {
+		return fmt.Errorf("积压任务 %d 个，达到上限 %d", backlog, queue.healthBacklog)
+	}
 	return nil
 }
 
+func (queue *TaskQueue) process(worker int, action *TaskAction) {
+	p, ok := queue.processes.Load(action.Name)
+
+	if !ok {
+		queue.Logger.Error().Str("name", action.Name).Str("id", action.ID).Str("action", "没有该类型的处理器").Msg("[TASK]")
+		queue.setStatus(action, TASK_STATUS_NOT_HAVE_PROCESS)
+		return
+	}
+	process := p.(*taskProcess)
+
+	ctx, cancel := context.WithCancelCause(queue.baseCtx)
+	defer cancel(nil)
+	if process.conf.Timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, process.conf.Timeout, ErrTaskTimeout)
+		defer cancelTimeout()
+	}
+	// 只执行 Pending 、Retry 的任务，同一任务重复入队时只执行一次。
+	// 与 CancelTask 互斥，任务要么在开始前被取消，要么开始后通过 Context 取消。
+	var previous TaskStatus
+	queue.runningMutex.Lock()
+	result, err := queue.updateResult(action.ID, func(r *TaskResult) {
+		previous = r.Status
+		if previous != TASK_STATUS_PENDING && previous != TASK_STATUS_RETRY {
+			return
+		}
+		r.Status = TASK_STATUS_START
+		r.Attempts++
+	})
+	if err == nil && (previous == TASK_STATUS_PENDING || previous == TASK_STATUS_RETRY) {
+		queue.running[action.ID] = cancel
+		defer func() {
+			queue.runningMutex.Lock()
+			delete(queue.running, action.ID)
+			queue.runningMutex.Unlock()
+		}()
+	}
+	queue.runningMutex.Unlock()
+	if err != nil {
+		queue.Logger.Error().Str("name", action.Name).Str("id", action.ID).Str("action", "更新任务状态出错").Err(err).Msg("[TASK]")
+		return
+	}
+	if previous == TASK_STATUS_CANCELLED {
+		queue.Logger.Info().Str("name", action.Name).Str("id", action.ID).Str("action", "任务已取消，跳过").Msg("[TASK]")
+		return
+	}
+	if previous != TASK_STATUS_PENDING && previous != TASK_STATUS_RETRY {
+		queue.Logger.Debug().Str("name", action.Name).Str("id", action.ID).Str("status", string(previous)).Str("action", "任务已执行，跳过").Msg("[TASK]")
+		return
+	}
+
+	// 任务在新的 trace 中执行，链接到推送任务的请求。
+	spanOptions := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("task.name", action.Name),
+			attribute.String("task.id", action.ID),
+			attribute.Int("task.attempts", result.Attempts),
+		),
+	}
+	if linked := trace.SpanContextFromContext(ExtractTraceContext(context.Background(), action.Trace)); linked.IsValid() {
+		spanOptions = append(spanOptions, trace.WithLinks(trace.Link{SpanContext: linked}), trace.WithNewRoot())
+	}
+	spanCtx, span := queue.tracing.StartSpan(ctx, "task "+action.Name, spanOptions...)
+
+	with := queue.Logger.With().Str("taskId", action.ID).Str("taskName", action.Name)
+	if traceId := GetTraceID(spanCtx); len(traceId) > 0 {
+		with = with.Str("traceId", traceId)
+	}
+	spanCtx = with.Logger().WithContext(spanCtx)
+
+	a := *action
+	a.queue = queue
+	a.ctx = context.WithValue(spanCtx, taskActionContextKey{}, &a)
+	start := time.Now()
+	data, err := process.process(&a)
+	duration := time.Since(start)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	if err == nil {
+		queue.metrics.observeTask(action.Name, TASK_STATUS_OK, duration)
+		queue.update(action, func(r *TaskResult) {
+			r.Status = TASK_STATUS_OK
+			r.Data = data
+			r.LastError = ""
+			r.Progress = 100
+		})
+		queue.Logger.Info().
+			Str("action", "完成任务").
+			Str("name", action.Name).
+			Str("id", action.ID).
+			Int("worker", worker).
+			Int("attempts", result.Attempts).
+			Any("result", data).
+			Msg("[TASK]")
+		return
+	}
+
+	// 主动取消
+	if errors.Is(context.Cause(ctx), ErrTaskCancelled) {
+		queue.metrics.observeTask(action.Name, TASK_STATUS_CANCELLED, duration)
+		queue.update(action, func(r *TaskResult) {
+			r.Status = TASK_STATUS_CANCELLED
+			r.Data = data
+			r.LastError = err.Error()
+		})
+		queue.Logger.Info().
+			Str("action", "任务已取消").
+			Str("name", action.Name).
+			Str("id", action.ID).
+			Int("worker", worker).
+			Msg("[TASK]")
+		return
+	}
+
+	// 队列关闭，下次启动时重新执行
+	if queue.baseCtx.Err() != nil {
+		queue.metrics.observeTask(action.Name, TASK_STATUS_PENDING, duration)
+		queue.update(action, func(r *TaskResult) {
+			r.Status = TASK_STATUS_PENDING
+			r.LastError = err.Error()
+		})
+		queue.Logger.Warn().
+			Str("action", "队列关闭，任务中断").
+			Str("name", action.Name).
+			Str("id", action.ID).
+			Int("worker", worker).
+			Msg("[TASK]")
+		return
+	}
+
+	status := TASK_STATUS_FAILED
+	if errors.Is(context.Cause(ctx), ErrTaskTimeout) {
+		status = TASK_STATUS_TIMEOUT
+		if !errors.Is(err, ErrTaskTimeout) {
+			err = fmt.Errorf("%w: %w", ErrTaskTimeout, err)
+		}
+	}
+	retry := process.conf.Retry
+	if retry != nil {
+		if retry.CanRetry(result.Attempts, err) {
+			status = TASK_STATUS_RETRY
+		} else {
+			status = TASK_STATUS_DEAD
+		}
+	}
+	queue.metrics.observeTask(action.Name, status, duration)
+	queue.update(action, func(r *TaskResult) {
+		r.Status = status
+		r.Data = data
+		r.LastError = err.Error()
+	})
+	queue.Logger.Error().
+		Str("action", "任务处理出错").
+		Str("name", action.Name).
+		Str("id", action.ID).
+		Str("status", string(status)).
+		Int("worker", worker).
+		Int("attempts", result.Attempts).
+		Any("result", data).
+		AnErr("error", err).
+		Msg("[TASK]")
+
+	if status == TASK_STATUS_RETRY {
+		backoff := retry.Backoff(result.Attempts)
+		queue.Logger.Info().
+			Str("action", "等待重试").
+			Str("name", action.Name).
+			Str("id", action.ID).
+			Dur("backoff", backoff).
+			Msg("[TASK]")
+		queue.armTaskTimer(action, backoff, func() {
+			queue.enqueue(action)
+		})
+	}
+}
+
+// 更新任务结果，并通知订阅者。
+func (queue *TaskQueue) updateResult(id string, update func(r *TaskResult)) (*TaskResult, error) {
+	result, err := queue.store.Update(id, func(r *TaskResult) {
+		update(r)
+		r.UpdatedAt = queue.clock.Now()
+	})
+	if err != nil {
+		return nil, err
+	}
+	queue.publish(result)
+	return result, nil
+}
+
+func (queue *TaskQueue) update(action *TaskAction, update func(r *TaskResult)) {
+	if _, err := queue.updateResult(action.ID, update); err != nil {
+		queue.Logger.Error().Str("name", action.Name).Str("id", action.ID).Str("action", "更新任务出错").Err(err).Msg("[TASK]")
+	}
+}
+
+// 阻塞入队，队列停止时放弃，任务保留未完成状态。
+func (queue *TaskQueue) enqueue(action *TaskAction) {
+	select {
+	case queue.unprocessed <- action:
+	case <-queue.quit:
+	}
+}
+
 func (queue *TaskQueue) RegisterProcess(name string, process TaskActionProcess) {
-	queue.processes.Store(name, process)
+	queue.RegisterProcessWithConf(name, process, &TaskProcessConf{})
 }
 
+func (queue *TaskQueue) RegisterProcessWithConf(name string, process TaskActionProcess, conf *TaskProcessConf) {
+	if conf == nil {
+		conf = &TaskProcessConf{}
+	}
+	queue.processes.Store(name, &taskProcess{
+		process: process,
+		conf:    conf,
+	})
+}
+
+// 已注册处理器带有校验时，在推送时校验参数。
+func (queue *TaskQueue) validate(name string, param TaskActionParam) error {
+	p, ok := queue.processes.Load(name)
+	if !ok || p.(*taskProcess).validate == nil {
+		return nil
+	}
+	if err := p.(*taskProcess).validate(param); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrTaskParamInvalid, name, err)
+	}
+	return nil
+}
+
+// 取消任务，执行中的任务通过 Context 通知，未执行的任务不再执行。
+func (queue *TaskQueue) CancelTask(id string) error {
+	// 查找执行中的任务和更新状态在同一锁内，避免任务在两者之间开始执行。
+	queue.runningMutex.Lock()
+	if cancel, isRunning := queue.running[id]; isRunning {
+		queue.runningMutex.Unlock()
+		cancel(ErrTaskCancelled)
+		return nil
+	}
+	var status TaskStatus
+	_, err := queue.updateResult(id, func(r *TaskResult) {
+		status = r.Status
+		if IsTaskUnfinished(r.Status) || r.Status == TASK_STATUS_DELAYED {
+			r.Status = TASK_STATUS_CANCELLED
+		}
+	})
+	queue.runningMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	if !IsTaskUnfinished(status) && status != TASK_STATUS_DELAYED {
+		return fmt.Errorf("任务 %s 状态为 %s，不可取消", id, status)
+	}
+
+	queue.timerMutex.Lock()
+	if timer, ok := queue.timers[id]; ok {
+		timer.Stop()
+		delete(queue.timers, id)
+	}
+	queue.timerMutex.Unlock()
+	return nil
+}
+
+// 死信任务，即重试耗尽或不可重试的任务。
+func (queue *TaskQueue) ListDeadTasks() ([]*TaskResult, error) {
+	return queue.store.List(TASK_STATUS_DEAD)
+}
+
+// 重新投递死信、失败、超时或已取消的任务，重试次数清零。
+func (queue *TaskQueue) RedriveTask(id string) error {
+	result, err := queue.store.Query(id)
+	if err != nil {
+		return err
+	}
+	switch result.Status {
+	case TASK_STATUS_DEAD, TASK_STATUS_FAILED, TASK_STATUS_TIMEOUT, TASK_STATUS_CANCELLED:
+	default:
+		return fmt.Errorf("任务 %s 状态为 %s，不可重新投递", id, result.Status)
+	}
+	action, err := queue.store.QueryAction(id)
+	if err != nil {
+		return err
+	}
+	if _, err := queue.updateResult(id, func(r *TaskResult) {
+		r.Status = TASK_STATUS_PENDING
+		r.Attempts = 0
+		r.Progress = 0
+		r.Message = ""
+	}); err != nil {
+		return err
+	}
+	select {
+	case <-queue.quit:
+		return ErrTaskQueueStopped
+	default:
+	}
+	go queue.enqueue(action)
+	return nil
+}
+
+// 队列满时按 PushTimeout 等待，超时返回 ErrTaskQueueFull 。
 func (queue *TaskQueue) PushTask(name string, param TaskActionParam) (string, error) {
+	if queue.pushTimeout <= 0 {
+		return queue.push(nil, nil, name, param)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queue.pushTimeout)
+	defer cancel()
+	return queue.push(ctx.Done(), nil, name, param)
+}
+
+// 队列满时等待，直到 ctx 结束，返回 ErrTaskQueueFull 。
+func (queue *TaskQueue) PushTaskContext(ctx context.Context, name string, param TaskActionParam) (string, error) {
+	return queue.pushWithSpan(ctx, name, func(ctx context.Context, traceContext map[string]string) (string, error) {
+		return queue.push(ctx.Done(), traceContext, name, param)
+	})
+}
+
+// 在 push span 中推送，任务记录该 span 的追踪上下文。
+func (queue *TaskQueue) pushWithSpan(ctx context.Context, name string, push func(ctx context.Context, traceContext map[string]string) (string, error)) (string, error) {
+	ctx, span := queue.tracing.StartSpan(ctx, "push "+name,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("task.name", name)),
+	)
+	defer span.End()
+	id, err := push(ctx, InjectTraceContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(attribute.String("task.id", id))
+	}
+	return id, err
+}
+
+func (queue *TaskQueue) push(wait <-chan struct{}, traceContext map[string]string, name string, param TaskActionParam) (string, error) {
+	select {
+	case <-queue.quit:
+		return "", ErrTaskQueueStopped
+	default:
+	}
+	if err := queue.validate(name, param); err != nil {
+		return "", err
+	}
+
 	id, err := uuid.NewUUID()
 	if err != nil {
 		return "", err
@@ -140,26 +899,57 @@ func (queue *TaskQueue) PushTask(name string, param TaskActionParam) (string, er
 		ID:    id.String(),
 		Name:  name,
 		Param: param,
+		Trace: traceContext,
 	}
+	now := queue.clock.Now()
 	result := &TaskResult{
-		ID:     action.ID,
-		Name:   action.Name,
-		Status: TASK_STATUS_PENDING,
+		ID:        action.ID,
+		Name:      action.Name,
+		Status:    TASK_STATUS_PENDING,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := queue.store.Create(action, result); err != nil {
+		return "", err
+	}
+
+	// 不等待
+	if wait == nil {
+		select {
+		case queue.unprocessed <- action:
+			return action.ID, nil
+		default:
+			queue.store.Delete(action.ID)
+			return "", ErrTaskQueueFull
+		}
+	}
+
+	select {
+	case queue.unprocessed <- action:
+		return action.ID, nil
+	case <-queue.quit:
+		queue.store.Delete(action.ID)
+		return "", ErrTaskQueueStopped
+	case <-wait:
+		queue.store.Delete(action.ID)
+		return "", ErrTaskQueueFull
 	}
-	queue.results.Store(action.ID, result)
-	queue.unprocessed <- action
-	return id.String(), nil
 }
 
 func (queue *TaskQueue) QueryTask(id string) (*TaskResult, error) {
-	if result, ok := queue.results.Load(id); ok {
-		return result.(*TaskResult), nil
-	}
-	return nil, fmt.Errorf("没有 ID：%s 的队列信息", id)
+	return queue.store.Query(id)
+}
+
+// 按状态列出任务，statuses 为空时列出全部。
+func (queue *TaskQueue) ListTasks(statuses ...TaskStatus) ([]*TaskResult, error) {
+	return queue.store.List(statuses...)
 }
 
 func LoadTaskConfFromEnv(logger *zerolog.Logger) (*TaskConfig, error) {
 	logger.Info().Str("action", "通过环境变量配置任务队列").Msg("[TASK]")
 	conf := &TaskConfig{}
+	if err := LoadConf(conf); err != nil {
+		return nil, err
+	}
 	return conf, nil
 }
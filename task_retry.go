@@ -0,0 +1,72 @@
+package cjungo
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// 任务重试策略，退避时间按指数增长并加入随机抖动。
+type TaskRetryPolicy struct {
+	MaxAttempts    int           // 最多执行次数（包含首次）
+	InitialBackoff time.Duration // 默认 1s
+	MaxBackoff     time.Duration // 默认 5m
+	Multiplier     float64       // 默认 2
+	Jitter         float64       // 抖动比例 0~1
+	IsRetryable    func(err error) bool
+}
+
+func (policy *TaskRetryPolicy) CanRetry(attempts int, err error) bool {
+	if attempts >= policy.MaxAttempts {
+		return false
+	}
+	var permanent *TaskPermanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	if policy.IsRetryable != nil {
+		return policy.IsRetryable(err)
+	}
+	return true
+}
+
+// 第 attempts 次失败后的等待时间。
+func (policy *TaskRetryPolicy) Backoff(attempts int) time.Duration {
+	initial := policy.InitialBackoff
+	if initial <= 0 {
+		initial = time.Second
+	}
+	maxBackoff := policy.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 5 * time.Minute
+	}
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	backoff := float64(initial) * math.Pow(multiplier, float64(Max(attempts-1, 0)))
+	backoff = math.Min(backoff, float64(maxBackoff))
+	if jitter := Limit(0, 1, policy.Jitter); jitter > 0 {
+		backoff *= 1 + jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(backoff)
+}
+
+// 不可重试的错误，任务直接进入死信。
+type TaskPermanentError struct {
+	Reason error
+}
+
+func NewTaskPermanentError(err error) *TaskPermanentError {
+	return &TaskPermanentError{Reason: err}
+}
+
+func (err *TaskPermanentError) Error() string {
+	return err.Reason.Error()
+}
+
+func (err *TaskPermanentError) Unwrap() error {
+	return err.Reason
+}
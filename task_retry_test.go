@@ -0,0 +1,217 @@
+package cjungo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// 等待 ManualClock 上有 count 个定时器，定时器在任务状态更新后才注册。
+func waitManualTimers(t *testing.T, clock *ManualClock, count int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		clock.mutex.Lock()
+		got := len(clock.timers)
+		clock.mutex.Unlock()
+		if got == count {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("定时器 %d 个，应为 %d 个", got, count)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// 等待任务第 attempts 次执行失败后进入 Retry 。
+func waitTaskRetry(t *testing.T, queue *TaskQueue, id string, attempts int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		result, err := queue.QueryTask(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status == TASK_STATUS_RETRY && result.Attempts == attempts {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("任务状态为 %s，执行 %d 次", result.Status, result.Attempts)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTaskRetryPolicyBackoff(t *testing.T) {
+	policy := &TaskRetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second, Multiplier: 2}
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if got := policy.Backoff(i + 1); got != want {
+			t.Errorf("第 %d 次为 %v，应为 %v", i+1, got, want)
+		}
+	}
+
+	// 默认 1s 起，乘 2 ，最多 5m 。
+	policy = &TaskRetryPolicy{}
+	for attempts, want := range map[int]time.Duration{0: time.Second, 1: time.Second, 2: 2 * time.Second, 20: 5 * time.Minute} {
+		if got := policy.Backoff(attempts); got != want {
+			t.Errorf("默认第 %d 次为 %v，应为 %v", attempts, got, want)
+		}
+	}
+
+	// 抖动在比例范围内。
+	policy = &TaskRetryPolicy{InitialBackoff: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if got := policy.Backoff(1); got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Fatalf("抖动后为 %v", got)
+		}
+	}
+}
+
+func TestTaskRetryPolicyCanRetry(t *testing.T) {
+	err := errors.New("失败")
+	policy := &TaskRetryPolicy{MaxAttempts: 3}
+	if !policy.CanRetry(2, err) || policy.CanRetry(3, err) {
+		t.Error("应按 MaxAttempts 重试")
+	}
+	if policy.CanRetry(1, NewTaskPermanentError(err)) {
+		t.Error("永久错误不应重试")
+	}
+	policy.IsRetryable = func(e error) bool { return !errors.Is(e, err) }
+	if policy.CanRetry(1, err) || !policy.CanRetry(1, errors.New("其他")) {
+		t.Error("应按 IsRetryable 判断")
+	}
+}
+
+func TestTaskQueueRetryDead(t *testing.T) {
+	queue, clock := newTestScheduleQueue(t)
+	isFailing := true
+	queue.RegisterProcessWithConf("flaky", func(action *TaskAction) (TaskResultMessage, error) {
+		if isFailing {
+			return nil, errors.New("失败")
+		}
+		return TaskResultMessage{"ok": true}, nil
+	}, &TaskProcessConf{Retry: &TaskRetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second}})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, queue)
+
+	// 按 1s 、2s 退避重试，第 3 次失败后进入死信。
+	id := pushTestTask(t, queue, "flaky")
+	for attempts, backoff := range []time.Duration{time.Second, 2 * time.Second} {
+		waitTaskRetry(t, queue, id, attempts+1)
+		waitManualTimers(t, clock, 1)
+		clock.Advance(backoff - time.Millisecond)
+		if result, _ := queue.QueryTask(id); result.Status != TASK_STATUS_RETRY {
+			t.Fatalf("未到退避时间，状态为 %s", result.Status)
+		}
+		clock.Advance(time.Millisecond)
+	}
+	if result := waitTaskStatus(t, queue, id, TASK_STATUS_DEAD); result.Attempts != 3 || result.LastError != "失败" {
+		t.Fatalf("死信为 %+v", result)
+	}
+	dead, err := queue.ListDeadTasks()
+	if err != nil || len(dead) != 1 || dead[0].ID != id {
+		t.Fatalf("死信为 %v %v", dead, err)
+	}
+
+	// 重新投递后重试次数清零。
+	isFailing = false
+	if err := queue.RedriveTask(id); err != nil {
+		t.Fatal(err)
+	}
+	if result := waitTaskStatus(t, queue, id, TASK_STATUS_OK); result.Attempts != 1 || result.LastError != "" {
+		t.Fatalf("重新投递后为 %+v", result)
+	}
+	if err := queue.RedriveTask(id); err == nil {
+		t.Error("已完成的任务不可重新投递")
+	}
+}
+
+func TestTaskQueuePermanentError(t *testing.T) {
+	queue, _ := newTestScheduleQueue(t)
+	queue.RegisterProcessWithConf("bad", func(action *TaskAction) (TaskResultMessage, error) {
+		return nil, NewTaskPermanentError(errors.New("参数错误"))
+	}, &TaskProcessConf{Retry: &TaskRetryPolicy{MaxAttempts: 5}})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, queue)
+
+	// 不重试，直接进入死信。
+	if result := waitTaskStatus(t, queue, pushTestTask(t, queue, "bad"), TASK_STATUS_DEAD); result.Attempts != 1 {
+		t.Fatalf("执行 %d 次", result.Attempts)
+	}
+}
+
+func TestTaskQueueFailedKeepStatus(t *testing.T) {
+	queue, _ := newTestScheduleQueue(t)
+	queue.RegisterProcess("fail", func(action *TaskAction) (TaskResultMessage, error) {
+		return TaskResultMessage{"step": 1.0}, errors.New("失败")
+	})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	id := pushTestTask(t, queue, "fail")
+	waitTaskStatus(t, queue, id, TASK_STATUS_FAILED)
+	stopTestTaskQueue(t, queue)
+
+	// 没有重试策略时失败即 Failed ，不会再被改为 Ok 。
+	result, err := queue.QueryTask(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != TASK_STATUS_FAILED || result.LastError != "失败" || result.Data["step"] != 1.0 {
+		t.Fatalf("结果为 %+v", result)
+	}
+}
+
+func TestTaskQueueStopRetryTimer(t *testing.T) {
+	queue, clock := newTestScheduleQueue(t)
+	queue.RegisterProcessWithConf("fail", func(action *TaskAction) (TaskResultMessage, error) {
+		return nil, errors.New("失败")
+	}, &TaskProcessConf{Retry: &TaskRetryPolicy{MaxAttempts: 3, InitialBackoff: time.Minute}})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	id := pushTestTask(t, queue, "fail")
+	waitTaskRetry(t, queue, id, 1)
+	waitManualTimers(t, clock, 1)
+
+	// 停止时取消等待重试的定时器，任务保持 Retry ，下次启动时恢复。
+	stopTestTaskQueue(t, queue)
+	waitManualTimers(t, clock, 0)
+	queue.timerMutex.Lock()
+	count := len(queue.timers)
+	queue.timerMutex.Unlock()
+	if count != 0 {
+		t.Fatalf("还有 %d 个定时器", count)
+	}
+	if result, _ := queue.QueryTask(id); result.Status != TASK_STATUS_RETRY {
+		t.Fatalf("状态为 %s", result.Status)
+	}
+}
+
+func TestTaskQueueCancelRetry(t *testing.T) {
+	queue, clock := newTestScheduleQueue(t)
+	queue.RegisterProcessWithConf("fail", func(action *TaskAction) (TaskResultMessage, error) {
+		return nil, errors.New("失败")
+	}, &TaskProcessConf{Retry: &TaskRetryPolicy{MaxAttempts: 3, InitialBackoff: time.Minute}})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, queue)
+	id := pushTestTask(t, queue, "fail")
+	waitTaskRetry(t, queue, id, 1)
+	waitManualTimers(t, clock, 1)
+
+	// 取消等待重试的任务时一并停止定时器。
+	if err := queue.CancelTask(id); err != nil {
+		t.Fatal(err)
+	}
+	waitManualTimers(t, clock, 0)
+	if result, _ := queue.QueryTask(id); result.Status != TASK_STATUS_CANCELLED || result.Attempts != 1 {
+		t.Fatalf("结果为 %+v", result)
+	}
+}
@@ -0,0 +1,324 @@
+package cjungo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TaskMissedPolicy string
+
+const (
+	TASK_MISSED_SKIP     TaskMissedPolicy = "Skip"    // 错过多次时只执行一次
+	TASK_MISSED_CATCH_UP TaskMissedPolicy = "CatchUp" // 错过的每次都补执行
+)
+
+type TaskScheduleConf struct {
+	Missed       TaskMissedPolicy // 默认 Skip
+	AllowOverlap bool             // 默认上次任务未完成时跳过本次
+	Since        *time.Time       // 从该时间起计算，默认注册时间
+}
+
+type TaskScheduleInfo struct {
+	ID         string
+	Expr       string
+	Name       string
+	Next       time.Time
+	LastTaskID string
+}
+
+type TaskUpcomingRun struct {
+	At         time.Time
+	Name       string
+	TaskID     string // 延迟任务 ID
+	ScheduleID string // 定时计划 ID
+}
+
+type taskSchedule struct {
+	id         string
+	expr       string
+	name       string
+	param      TaskActionParam
+	conf       *TaskScheduleConf
+	schedule   CronSchedule
+	last       time.Time
+	next       time.Time
+	lastTaskID string
+	timer      ClockTimer
+}
+
+// 延迟到 at 执行的任务。
+func (queue *TaskQueue) PushTaskAt(name string, param TaskActionParam, at time.Time) (string, error) {
+	return queue.pushAt(nil, name, param, at)
+}
+
+// 同 PushTaskAt ，并记录 ctx 的追踪上下文，任务的 span 链接到推送任务的请求。
+func (queue *TaskQueue) PushTaskAtContext(ctx context.Context, name string, param TaskActionParam, at time.Time) (string, error) {
+	return queue.pushWithSpan(ctx, name, func(ctx context.Context, traceContext map[string]string) (string, error) {
+		return queue.pushAt(traceContext, name, param, at)
+	})
+}
+
+func (queue *TaskQueue) PushTaskAfter(name string, param TaskActionParam, d time.Duration) (string, error) {
+	return queue.PushTaskAt(name, param, queue.clock.Now().Add(d))
+}
+
+func (queue *TaskQueue) PushTaskAfterContext(ctx context.Context, name string, param TaskActionParam, d time.Duration) (string, error) {
+	return queue.PushTaskAtContext(ctx, name, param, queue.clock.Now().Add(d))
+}
+
+func (queue *TaskQueue) pushAt(traceContext map[string]string, name string, param TaskActionParam, at time.Time) (string, error) {
+	select {
+	case <-queue.quit:
+		return "", ErrTaskQueueStopped
+	default:
+	}
+	if err := queue.validate(name, param); err != nil {
+		return "", err
+	}
+
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return "", err
+	}
+	action := &TaskAction{
+		ID:    id.String(),
+		Name:  name,
+		Param: param,
+		Trace: traceContext,
+	}
+	now := queue.clock.Now()
+	result := &TaskResult{
+		ID:        action.ID,
+		Name:      action.Name,
+		Status:    TASK_STATUS_DELAYED,
+		RunAt:     &at,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := queue.store.Create(action, result); err != nil {
+		return "", err
+	}
+	queue.armDelay(action, at)
+	return action.ID, nil
+}
+
+func (queue *TaskQueue) armDelay(action *TaskAction, at time.Time) {
+	queue.armTaskTimer(action, at.Sub(queue.clock.Now()), func() {
+		queue.setStatus(action, TASK_STATUS_PENDING)
+		queue.enqueue(action)
+	})
+}
+
+// 任务的定时器（延迟执行、等待重试），队列停止或任务取消时停止。
+// 队列已停止时不再设置，任务保持未完成或延迟状态，下次启动时恢复。
+func (queue *TaskQueue) armTaskTimer(action *TaskAction, d time.Duration, fire func()) {
+	queue.timerMutex.Lock()
+	defer queue.timerMutex.Unlock()
+	select {
+	case <-queue.quit:
+		return
+	default:
+	}
+	if _, ok := queue.timers[action.ID]; ok {
+		return
+	}
+	queue.timers[action.ID] = queue.clock.AfterFunc(d, func() {
+		queue.timerMutex.Lock()
+		delete(queue.timers, action.ID)
+		queue.timerMutex.Unlock()
+		fire()
+	})
+}
+
+func (queue *TaskQueue) Schedule(expr string, name string, param TaskActionParam) (string, error) {
+	return queue.ScheduleWithConf(expr, name, param, &TaskScheduleConf{})
+}
+
+// 按 cron 表达式定时推送任务，返回计划 ID 。
+// 定时推送没有调用方的请求，每次的任务在新的 trace 中执行。
+func (queue *TaskQueue) ScheduleWithConf(expr string, name string, param TaskActionParam, conf *TaskScheduleConf) (string, error) {
+	if conf == nil {
+		conf = &TaskScheduleConf{}
+	}
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return "", err
+	}
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return "", err
+	}
+	s := &taskSchedule{
+		id:       id.String(),
+		expr:     expr,
+		name:     name,
+		param:    param,
+		conf:     conf,
+		schedule: schedule,
+		last:     GetOrDefault(conf.Since, queue.clock.Now()),
+	}
+	s.next = schedule.Next(s.last)
+
+	queue.mutex.Lock()
+	queue.schedules = append(queue.schedules, s)
+	isRunning := queue.done != nil
+	queue.mutex.Unlock()
+
+	if isRunning {
+		queue.armSchedule(s)
+	}
+
+	queue.Logger.Info().
+		Str("action", "注册定时任务").
+		Str("id", s.id).
+		Str("expr", expr).
+		Str("name", name).
+		Time("next", s.next).
+		Msg("[TASK]")
+	return s.id, nil
+}
+
+func (queue *TaskQueue) Unschedule(id string) error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for i, s := range queue.schedules {
+		if s.id == id {
+			if s.timer != nil {
+				s.timer.Stop()
+			}
+			queue.schedules = append(queue.schedules[:i], queue.schedules[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("没有 ID：%s 的定时任务", id)
+}
+
+func (queue *TaskQueue) armSchedule(s *taskSchedule) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	select {
+	case <-queue.quit:
+		return
+	default:
+	}
+	if s.next.IsZero() {
+		return
+	}
+	s.timer = queue.clock.AfterFunc(s.next.Sub(queue.clock.Now()), func() {
+		queue.fireSchedule(s)
+	})
+}
+
+func (queue *TaskQueue) fireSchedule(s *taskSchedule) {
+	queue.mutex.Lock()
+	now := queue.clock.Now()
+	occurrences := []time.Time{}
+	t := s.next
+	for !t.IsZero() && !t.After(now) && len(occurrences) < 1000 {
+		occurrences = append(occurrences, t)
+		t = s.schedule.Next(t)
+	}
+	s.last = now
+	s.next = t
+	lastTaskID := s.lastTaskID
+	queue.mutex.Unlock()
+
+	if len(occurrences) > 1 && s.conf.Missed != TASK_MISSED_CATCH_UP {
+		queue.Logger.Warn().
+			Str("action", "跳过错过的定时任务").
+			Str("id", s.id).
+			Str("name", s.name).
+			Int("missed", len(occurrences)-1).
+			Msg("[TASK]")
+		occurrences = occurrences[len(occurrences)-1:]
+	}
+
+	if len(occurrences) > 0 && !s.conf.AllowOverlap && len(lastTaskID) > 0 {
+		if r, err := queue.store.Query(lastTaskID); err == nil && IsTaskUnfinished(r.Status) {
+			queue.Logger.Warn().
+				Str("action", "上次定时任务未完成，跳过").
+				Str("id", s.id).
+				Str("name", s.name).
+				Str("lastTaskId", lastTaskID).
+				Msg("[TASK]")
+			occurrences = nil
+		}
+	}
+
+	for _, at := range occurrences {
+		taskID, err := queue.PushTask(s.name, s.param)
+		if err != nil {
+			queue.Logger.Error().
+				Str("action", "定时任务推送出错").
+				Str("id", s.id).
+				Str("name", s.name).
+				Time("at", at).
+				Err(err).
+				Msg("[TASK]")
+			continue
+		}
+		queue.mutex.Lock()
+		s.lastTaskID = taskID
+		queue.mutex.Unlock()
+	}
+
+	queue.armSchedule(s)
+}
+
+func (queue *TaskQueue) ListSchedules() []*TaskScheduleInfo {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	result := make([]*TaskScheduleInfo, len(queue.schedules))
+	for i, s := range queue.schedules {
+		result[i] = &TaskScheduleInfo{
+			ID:         s.id,
+			Expr:       s.expr,
+			Name:       s.name,
+			Next:       s.next,
+			LastTaskID: s.lastTaskID,
+		}
+	}
+	return result
+}
+
+// 列出 until 之前将要执行的延迟任务和定时任务，按时间排序。
+func (queue *TaskQueue) ListUpcoming(until time.Time) ([]*TaskUpcomingRun, error) {
+	result := []*TaskUpcomingRun{}
+
+	delayed, err := queue.store.List(TASK_STATUS_DELAYED)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range delayed {
+		if r.RunAt != nil && !r.RunAt.After(until) {
+			result = append(result, &TaskUpcomingRun{
+				At:     *r.RunAt,
+				Name:   r.Name,
+				TaskID: r.ID,
+			})
+		}
+	}
+
+	queue.mutex.Lock()
+	for _, s := range queue.schedules {
+		t := s.next
+		for i := 0; i < 1000 && !t.IsZero() && !t.After(until); i++ {
+			result = append(result, &TaskUpcomingRun{
+				At:         t,
+				Name:       s.name,
+				ScheduleID: s.id,
+			})
+			t = s.schedule.Next(t)
+		}
+	}
+	queue.mutex.Unlock()
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].At.Before(result[j].At)
+	})
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package cjungo
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestScheduleQueue(t *testing.T) (*TaskQueue, *ManualClock) {
+	t.Helper()
+	clock := NewManualClock(time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC))
+	queue := newTestTaskQueue(t, TaskQueueDi{Clock: clock})
+	queue.RegisterProcess("tick", func(action *TaskAction) (TaskResultMessage, error) {
+		return nil, nil
+	})
+	return queue, clock
+}
+
+func countTestTasks(t *testing.T, queue *TaskQueue) int {
+	t.Helper()
+	results, err := queue.ListTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(results)
+}
+
+func TestTaskQueuePushTaskAt(t *testing.T) {
+	queue, clock := newTestScheduleQueue(t)
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, queue)
+
+	id, err := queue.PushTaskAt("tick", TaskActionParam{}, clock.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(30 * time.Minute)
+	if result, _ := queue.QueryTask(id); result.Status != TASK_STATUS_DELAYED {
+		t.Fatalf("未到时间，状态为 %s", result.Status)
+	}
+	upcoming, err := queue.ListUpcoming(clock.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upcoming) != 1 || upcoming[0].TaskID != id {
+		t.Fatalf("将要执行的任务 %v", upcoming)
+	}
+
+	clock.Advance(30 * time.Minute)
+	waitTaskStatus(t, queue, id, TASK_STATUS_OK)
+}
+
+func TestTaskQueueScheduleMissed(t *testing.T) {
+	cases := []struct {
+		missed TaskMissedPolicy
+		count  int
+	}{
+		{TASK_MISSED_SKIP, 1},
+		{"", 1},
+		{TASK_MISSED_CATCH_UP, 3},
+	}
+	for _, c := range cases {
+		queue, clock := newTestScheduleQueue(t)
+		// 从 3 小时前起计算，错过 08:00 、09:00 、10:00 。
+		since := clock.Now().Add(-3 * time.Hour)
+		if _, err := queue.ScheduleWithConf("@hourly", "tick", nil, &TaskScheduleConf{
+			Missed: c.missed,
+			Since:  &since,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := queue.Run(); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(0)
+		if count := countTestTasks(t, queue); count != c.count {
+			t.Errorf("%s 推送 %d 个任务，应为 %d 个", c.missed, count, c.count)
+		}
+
+		// 上次任务完成后按时执行。
+		results, _ := queue.ListTasks()
+		for _, result := range results {
+			waitTaskStatus(t, queue, result.ID, TASK_STATUS_OK)
+		}
+		schedules := queue.ListSchedules()
+		if want := time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC); !schedules[0].Next.Equal(want) {
+			t.Errorf("下一次为 %v，应为 %v", schedules[0].Next, want)
+		}
+		clock.Advance(30 * time.Minute)
+		if count := countTestTasks(t, queue); count != c.count+1 {
+			t.Errorf("%s 到点后共 %d 个任务，应为 %d 个", c.missed, count, c.count+1)
+		}
+		stopTestTaskQueue(t, queue)
+	}
+}
+
+func TestTaskQueueScheduleNilConf(t *testing.T) {
+	queue, clock := newTestScheduleQueue(t)
+	if _, err := queue.ScheduleWithConf("@every 1m", "tick", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, queue)
+	clock.Advance(time.Minute)
+	if count := countTestTasks(t, queue); count != 1 {
+		t.Fatalf("推送 %d 个任务，应为 1 个", count)
+	}
+}
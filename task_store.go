@@ -0,0 +1,154 @@
+package cjungo
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 任务存储，默认使用内存，可替换为持久化实现。
+type TaskStore interface {
+	Create(action *TaskAction, result *TaskResult) error
+	Update(id string, update func(result *TaskResult)) (*TaskResult, error)
+	Query(id string) (*TaskResult, error)
+	QueryAction(id string) (*TaskAction, error)
+	Delete(id string) error
+	// 按状态列出，statuses 为空时列出全部。
+	List(statuses ...TaskStatus) ([]*TaskResult, error)
+	ListActions(statuses ...TaskStatus) ([]*TaskAction, error)
+	// 删除 before 之前更新且已结束的任务，返回删除数量。
+	Purge(before time.Time) (int, error)
+}
+
+// 已结束的任务，不会再被执行。
+func IsTaskFinished(status TaskStatus) bool {
+	return !IsTaskUnfinished(status) && status != TASK_STATUS_DELAYED
+}
+
+func isTaskStatusIn(status TaskStatus, statuses []TaskStatus) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+type taskMemoryItem struct {
+	action *TaskAction
+	result *TaskResult
+}
+
+type TaskMemoryStore struct {
+	mutex sync.RWMutex
+	items map[string]*taskMemoryItem
+}
+
+func NewTaskMemoryStore() *TaskMemoryStore {
+	return &TaskMemoryStore{
+		items: map[string]*taskMemoryItem{},
+	}
+}
+
+func (store *TaskMemoryStore) Create(action *TaskAction, result *TaskResult) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	r := *result
+	store.items[action.ID] = &taskMemoryItem{
+		action: action,
+		result: &r,
+	}
+	return nil
+}
+
+func (store *TaskMemoryStore) Update(id string, update func(result *TaskResult)) (*TaskResult, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	item, ok := store.items[id]
+	if !ok {
+		return nil, fmt.Errorf("%w：%s", ErrTaskNotFound, id)
+	}
+	update(item.result)
+	r := *item.result
+	return &r, nil
+}
+
+func (store *TaskMemoryStore) Query(id string) (*TaskResult, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	item, ok := store.items[id]
+	if !ok {
+		return nil, fmt.Errorf("%w：%s", ErrTaskNotFound, id)
+	}
+	r := *item.result
+	return &r, nil
+}
+
+func (store *TaskMemoryStore) QueryAction(id string) (*TaskAction, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	item, ok := store.items[id]
+	if !ok {
+		return nil, fmt.Errorf("%w：%s", ErrTaskNotFound, id)
+	}
+	return item.action, nil
+}
+
+func (store *TaskMemoryStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.items, id)
+	return nil
+}
+
+func (store *TaskMemoryStore) List(statuses ...TaskStatus) ([]*TaskResult, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	result := []*TaskResult{}
+	for _, item := range store.items {
+		if isTaskStatusIn(item.result.Status, statuses) {
+			r := *item.result
+			result = append(result, &r)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (store *TaskMemoryStore) ListActions(statuses ...TaskStatus) ([]*TaskAction, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	items := []*taskMemoryItem{}
+	for _, item := range store.items {
+		if isTaskStatusIn(item.result.Status, statuses) {
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].result.CreatedAt.Before(items[j].result.CreatedAt)
+	})
+	result := make([]*TaskAction, 0, len(items))
+	for _, item := range items {
+		result = append(result, item.action)
+	}
+	return result, nil
+}
+
+func (store *TaskMemoryStore) Purge(before time.Time) (int, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	count := 0
+	for id, item := range store.items {
+		if IsTaskFinished(item.result.Status) && item.result.UpdatedAt.Before(before) {
+			delete(store.items, id)
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,351 @@
+package cjungo
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// 不输出日志、使用内存存储的任务队列。
+func newTestTaskQueue(t *testing.T, di TaskQueueDi) *TaskQueue {
+	t.Helper()
+	logger := zerolog.Nop()
+	di.Logger = &logger
+	if di.Store == nil {
+		di.Store = NewTaskMemoryStore()
+	}
+	queue, err := NewTaskQueueHandle(func(*TaskQueue) error { return nil })(di)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return queue
+}
+
+func stopTestTaskQueue(t *testing.T, queue *TaskQueue) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := queue.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 等待任务达到指定状态。
+func waitTaskStatus(t *testing.T, queue *TaskQueue, id string, status TaskStatus) *TaskResult {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		result, err := queue.QueryTask(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status == status {
+			return result
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("任务 %s 状态为 %s，应为 %s", id, result.Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func pushTestTask(t *testing.T, queue *TaskQueue, name string) string {
+	t.Helper()
+	id, err := queue.PushTask(name, TaskActionParam{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestTaskQueueProcessLimitNotBlockOthers(t *testing.T) {
+	workerCount := 2
+	queue := newTestTaskQueue(t, TaskQueueDi{Conf: &TaskConfig{
+		WorkerCount:   &workerCount,
+		ProcessLimits: map[string]int{"slow": 1},
+	}})
+	gate := make(chan struct{})
+	queue.RegisterProcess("slow", func(action *TaskAction) (TaskResultMessage, error) {
+		<-gate
+		return nil, nil
+	})
+	queue.RegisterProcess("fast", func(action *TaskAction) (TaskResultMessage, error) {
+		return nil, nil
+	})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, queue)
+
+	slow1 := pushTestTask(t, queue, "slow")
+	waitTaskStatus(t, queue, slow1, TASK_STATUS_START)
+	slow2 := pushTestTask(t, queue, "slow")
+	fast := pushTestTask(t, queue, "fast")
+
+	// 排队的 slow 不占用工作协程，fast 不被阻塞。
+	waitTaskStatus(t, queue, fast, TASK_STATUS_OK)
+	if result, _ := queue.QueryTask(slow2); result.Status != TASK_STATUS_PENDING {
+		t.Fatalf("slow2 状态为 %s，应排队等待", result.Status)
+	}
+
+	close(gate)
+	waitTaskStatus(t, queue, slow1, TASK_STATUS_OK)
+	waitTaskStatus(t, queue, slow2, TASK_STATUS_OK)
+}
+
+func TestTaskQueueSetProcessLimitsReleaseWaiting(t *testing.T) {
+	queue := newTestTaskQueue(t, TaskQueueDi{Conf: &TaskConfig{
+		ProcessLimits: map[string]int{"slow": 1},
+	}})
+	gate := make(chan struct{})
+	queue.RegisterProcess("slow", func(action *TaskAction) (TaskResultMessage, error) {
+		<-gate
+		return nil, nil
+	})
+	queue.SetWorkerCount(2)
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, queue)
+
+	slow1 := pushTestTask(t, queue, "slow")
+	waitTaskStatus(t, queue, slow1, TASK_STATUS_START)
+	slow2 := pushTestTask(t, queue, "slow")
+	time.Sleep(20 * time.Millisecond)
+
+	// 取消限制后排队的任务立即执行。
+	queue.SetProcessLimits(nil)
+	waitTaskStatus(t, queue, slow2, TASK_STATUS_START)
+	close(gate)
+	waitTaskStatus(t, queue, slow1, TASK_STATUS_OK)
+	waitTaskStatus(t, queue, slow2, TASK_STATUS_OK)
+}
+
+func TestTaskQueueStopKeepWaitingPending(t *testing.T) {
+	store := NewTaskMemoryStore()
+	queue := newTestTaskQueue(t, TaskQueueDi{
+		Store: store,
+		Conf:  &TaskConfig{ProcessLimits: map[string]int{"slow": 1}},
+	})
+	started := make(chan struct{}, 2)
+	queue.RegisterProcess("slow", func(action *TaskAction) (TaskResultMessage, error) {
+		started <- struct{}{}
+		<-action.Context().Done()
+		return nil, action.Context().Err()
+	})
+	queue.SetWorkerCount(2)
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	slow1 := pushTestTask(t, queue, "slow")
+	<-started
+	slow2 := pushTestTask(t, queue, "slow")
+	time.Sleep(20 * time.Millisecond)
+
+	// 停止时中断的任务和排队的任务都保持 Pending 。
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	queue.Stop(ctx)
+	waitTaskStatus(t, queue, slow1, TASK_STATUS_PENDING)
+	waitTaskStatus(t, queue, slow2, TASK_STATUS_PENDING)
+
+	// 下次启动时恢复执行。
+	next := newTestTaskQueue(t, TaskQueueDi{Store: store})
+	done := make(chan struct{}, 2)
+	next.RegisterProcess("slow", func(action *TaskAction) (TaskResultMessage, error) {
+		done <- struct{}{}
+		return nil, nil
+	})
+	if err := next.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, next)
+	waitTaskStatus(t, next, slow1, TASK_STATUS_OK)
+	waitTaskStatus(t, next, slow2, TASK_STATUS_OK)
+}
+
+func TestTaskQueueRecoverOnce(t *testing.T) {
+	store := NewTaskMemoryStore()
+	now := time.Now()
+	if err := store.Create(&TaskAction{ID: "old", Name: "count"}, &TaskResult{ID: "old", Name: "count", Status: TASK_STATUS_PROCESSING, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	var mutex sync.Mutex
+	counts := map[string]int{}
+	logger := zerolog.Nop()
+	var pushed string
+	queue, err := NewTaskQueueHandle(func(queue *TaskQueue) error {
+		queue.RegisterProcess("count", func(action *TaskAction) (TaskResultMessage, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			counts[action.ID]++
+			return nil, nil
+		})
+		// 启动前推送的任务已在队列中，不应再次恢复。
+		id, err := queue.PushTask("count", TaskActionParam{})
+		pushed = id
+		return err
+	})(TaskQueueDi{Store: store, Logger: &logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, queue)
+
+	waitTaskStatus(t, queue, "old", TASK_STATUS_OK)
+	waitTaskStatus(t, queue, pushed, TASK_STATUS_OK)
+	time.Sleep(20 * time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if counts["old"] != 1 || counts[pushed] != 1 {
+		t.Fatalf("执行次数 %v，每个任务应只执行一次", counts)
+	}
+}
+
+func TestTaskQueueSkipDuplicateEnqueue(t *testing.T) {
+	queue := newTestTaskQueue(t, TaskQueueDi{})
+	var mutex sync.Mutex
+	count := 0
+	queue.RegisterProcess("count", func(action *TaskAction) (TaskResultMessage, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		count++
+		return nil, nil
+	})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, queue)
+
+	id := pushTestTask(t, queue, "count")
+	waitTaskStatus(t, queue, id, TASK_STATUS_OK)
+	// 已完成的任务再次入队时跳过。
+	action, err := queue.store.QueryAction(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.enqueue(action)
+	time.Sleep(20 * time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if count != 1 {
+		t.Fatalf("执行 %d 次，应为 1 次", count)
+	}
+}
+
+func TestTaskMemoryStoreListActionsOrder(t *testing.T) {
+	store := NewTaskMemoryStore()
+	now := time.Now()
+	for i, id := range []string{"c", "a", "d", "b"} {
+		createdAt := now.Add(time.Duration(i) * time.Second)
+		if err := store.Create(&TaskAction{ID: id}, &TaskResult{ID: id, Status: TASK_STATUS_PENDING, CreatedAt: createdAt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	actions, err := store.ListActions(TASK_STATUS_PENDING)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ""
+	for _, action := range actions {
+		got += action.ID
+	}
+	if got != "cadb" {
+		t.Fatalf("顺序为 %s，应按创建时间为 cadb", got)
+	}
+}
+
+func TestTaskQueueRegisterProcessNilConf(t *testing.T) {
+	queue := newTestTaskQueue(t, TaskQueueDi{})
+	queue.RegisterProcessWithConf("nil", func(action *TaskAction) (TaskResultMessage, error) {
+		return nil, nil
+	}, nil)
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, queue)
+	id := pushTestTask(t, queue, "nil")
+	waitTaskStatus(t, queue, id, TASK_STATUS_OK)
+}
+
+func TestTaskQueueCancelTaskRace(t *testing.T) {
+	workerCount := 4
+	queue := newTestTaskQueue(t, TaskQueueDi{Conf: &TaskConfig{WorkerCount: &workerCount}})
+	queue.RegisterProcess("wait", func(action *TaskAction) (TaskResultMessage, error) {
+		select {
+		case <-action.Context().Done():
+			return nil, action.Context().Err()
+		case <-time.After(50 * time.Millisecond):
+			return nil, nil
+		}
+	})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, queue)
+
+	// 无论取消时任务是否已开始，最终都是 Cancelled 。
+	ids := []string{}
+	for i := 0; i < 32; i++ {
+		id := pushTestTask(t, queue, "wait")
+		if err := queue.CancelTask(id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		waitTaskStatus(t, queue, id, TASK_STATUS_CANCELLED)
+	}
+	time.Sleep(100 * time.Millisecond)
+	for _, id := range ids {
+		if result, _ := queue.QueryTask(id); result.Status != TASK_STATUS_CANCELLED {
+			t.Fatalf("任务 %s 取消后状态为 %s", id, result.Status)
+		}
+	}
+}
+
+func TestTaskQueueProcessTimeout(t *testing.T) {
+	queue := newTestTaskQueue(t, TaskQueueDi{})
+	isDone := make(chan bool, 2)
+	wait := func(action *TaskAction) (TaskResultMessage, error) {
+		select {
+		case <-action.Context().Done():
+			isDone <- true
+			return nil, action.Context().Err()
+		case <-time.After(5 * time.Second):
+			isDone <- false
+			return nil, nil
+		}
+	}
+	queue.RegisterProcessWithConf("slow", wait, &TaskProcessConf{Timeout: 20 * time.Millisecond})
+	queue.RegisterProcessWithConf("retry", wait, &TaskProcessConf{
+		Timeout: 20 * time.Millisecond,
+		Retry:   &TaskRetryPolicy{MaxAttempts: 1},
+	})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, queue)
+
+	// 超过 Timeout 时 Context 结束，没有重试策略时为 Timeout 。
+	result := waitTaskStatus(t, queue, pushTestTask(t, queue, "slow"), TASK_STATUS_TIMEOUT)
+	if !<-isDone {
+		t.Fatal("超时后 Context 应结束")
+	}
+	if !strings.Contains(result.LastError, "任务执行超时") {
+		t.Errorf("错误为 %s", result.LastError)
+	}
+
+	// 有重试策略时按策略处理，重试耗尽进入死信。
+	result = waitTaskStatus(t, queue, pushTestTask(t, queue, "retry"), TASK_STATUS_DEAD)
+	if !<-isDone || !strings.Contains(result.LastError, "任务执行超时") {
+		t.Errorf("错误为 %s", result.LastError)
+	}
+}
@@ -0,0 +1,142 @@
+package cjungo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+type TaskTypedProcess[P any, R any] func(ctx context.Context, param P) (R, error)
+
+// 参数实现该接口时，推送任务时会调用校验。
+type TaskParamValidator interface {
+	Validate() error
+}
+
+type taskActionContextKey struct{}
+
+// 任务执行时从 ctx 中取得当前任务。
+func TaskActionFromContext(ctx context.Context) (*TaskAction, bool) {
+	action, ok := ctx.Value(taskActionContextKey{}).(*TaskAction)
+	return action, ok
+}
+
+// 在类型化处理器中更新任务进度。
+func ReportTaskProgress(ctx context.Context, percent float64, message string) error {
+	if action, ok := TaskActionFromContext(ctx); ok {
+		return action.Progress(percent, message)
+	}
+	return nil
+}
+
+func RegisterTyped[P any, R any](queue *TaskQueue, name string, process TaskTypedProcess[P, R]) {
+	RegisterTypedWithConf(queue, name, process, &TaskProcessConf{})
+}
+
+// 注册类型化处理器，参数和结果通过 JSON 与 TaskActionParam、TaskResultMessage 转换，
+// 推送任务时校验参数。
+func RegisterTypedWithConf[P any, R any](queue *TaskQueue, name string, process TaskTypedProcess[P, R], conf *TaskProcessConf) {
+	if conf == nil {
+		conf = &TaskProcessConf{}
+	}
+	queue.processes.Store(name, &taskProcess{
+		process: func(action *TaskAction) (TaskResultMessage, error) {
+			var param P
+			if err := DecodeTaskMessage(action.Param, &param); err != nil {
+				return nil, NewTaskPermanentError(fmt.Errorf("%w: %v", ErrTaskParamInvalid, err))
+			}
+			result, err := process(action.Context(), param)
+			if err != nil {
+				return nil, err
+			}
+			return EncodeTaskMessage(result)
+		},
+		conf: conf,
+		validate: func(m TaskActionParam) error {
+			var param P
+			if err := decodeTaskMessageStrict(m, &param); err != nil {
+				return err
+			}
+			if validator, ok := any(&param).(TaskParamValidator); ok {
+				return validator.Validate()
+			}
+			if validator, ok := any(param).(TaskParamValidator); ok {
+				return validator.Validate()
+			}
+			return nil
+		},
+	})
+}
+
+func PushTyped[P any](queue *TaskQueue, name string, param P) (string, error) {
+	m, err := EncodeTaskMessage(param)
+	if err != nil {
+		return "", err
+	}
+	return queue.PushTask(name, m)
+}
+
+// 查询任务并把结果解码为 R ，任务未完成时 R 为空。
+func QueryTyped[R any](queue *TaskQueue, id string) (*R, *TaskResult, error) {
+	result, err := queue.QueryTask(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.Status != TASK_STATUS_OK {
+		return nil, result, nil
+	}
+	var data R
+	if err := DecodeTaskMessage(result.Data, &data); err != nil {
+		return nil, result, err
+	}
+	return &data, result, nil
+}
+
+// 对象编码为字段，其他值编码为 {"value": v} 。
+func EncodeTaskMessage(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if isTaskMessageObject(reflect.TypeOf(v)) {
+		m := map[string]any{}
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	return map[string]any{"value": json.RawMessage(data)}, nil
+}
+
+func DecodeTaskMessage[M ~map[string]any](m M, v any) error {
+	return decodeTaskMessage(m, v, false)
+}
+
+func decodeTaskMessageStrict[M ~map[string]any](m M, v any) error {
+	return decodeTaskMessage(m, v, true)
+}
+
+func decodeTaskMessage[M ~map[string]any](m M, v any, strict bool) error {
+	var source any = map[string]any(m)
+	if !isTaskMessageObject(reflect.TypeOf(v)) {
+		source = m["value"]
+	}
+	data, err := json.Marshal(source)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(v)
+}
+
+func isTaskMessageObject(t reflect.Type) bool {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t != nil && (t.Kind() == reflect.Struct || t.Kind() == reflect.Map)
+}
@@ -0,0 +1,126 @@
+package cjungo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type testTypedParam struct {
+	A int    `json:"a"`
+	B string `json:"b"`
+}
+
+func (param testTypedParam) Validate() error {
+	if param.A < 0 {
+		return fmt.Errorf("a 不能为负数")
+	}
+	return nil
+}
+
+type testTypedResult struct {
+	Sum int `json:"sum"`
+}
+
+func TestTaskMessageEncodeDecode(t *testing.T) {
+	// 对象编码为字段。
+	m, err := EncodeTaskMessage(testTypedParam{A: 1, B: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["a"] != float64(1) || m["b"] != "x" {
+		t.Fatalf("编码为 %v", m)
+	}
+	var param testTypedParam
+	if err := DecodeTaskMessage(m, &param); err != nil {
+		t.Fatal(err)
+	}
+	if param != (testTypedParam{A: 1, B: "x"}) {
+		t.Fatalf("解码为 %v", param)
+	}
+
+	// 其他值编码为 value 。
+	for _, v := range []any{42, "text", []int{1, 2}, true} {
+		m, err := EncodeTaskMessage(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := m["value"]; !ok || len(m) != 1 {
+			t.Fatalf("%v 编码为 %v", v, m)
+		}
+		decoded := reflect.New(reflect.TypeOf(v))
+		if err := DecodeTaskMessage(m, decoded.Interface()); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded.Elem().Interface(), v) {
+			t.Fatalf("%v 解码为 %v", v, decoded.Elem().Interface())
+		}
+	}
+}
+
+func TestTaskTypedValidate(t *testing.T) {
+	queue := newTestTaskQueue(t, TaskQueueDi{})
+	RegisterTyped(queue, "sum", func(ctx context.Context, param testTypedParam) (testTypedResult, error) {
+		return testTypedResult{}, nil
+	})
+	defer stopTestTaskQueue(t, queue)
+
+	// 推送时校验：未知字段、类型错误和 Validate 。
+	for _, param := range []TaskActionParam{
+		{"a": 1, "c": 2},
+		{"a": "x"},
+		{"a": -1},
+	} {
+		if _, err := queue.PushTask("sum", param); !errors.Is(err, ErrTaskParamInvalid) {
+			t.Errorf("%v 应校验失败，实际为 %v", param, err)
+		}
+	}
+	if _, err := PushTyped(queue, "sum", testTypedParam{A: 1}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTaskTypedRun(t *testing.T) {
+	queue := newTestTaskQueue(t, TaskQueueDi{})
+	RegisterTypedWithConf(queue, "sum", func(ctx context.Context, param testTypedParam) (testTypedResult, error) {
+		if err := ReportTaskProgress(ctx, 50, "计算中"); err != nil {
+			return testTypedResult{}, err
+		}
+		return testTypedResult{Sum: param.A + len(param.B)}, nil
+	}, nil)
+	RegisterTyped(queue, "count", func(ctx context.Context, param []string) (int, error) {
+		return len(param), nil
+	})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer stopTestTaskQueue(t, queue)
+
+	id, err := PushTyped(queue, "sum", testTypedParam{A: 1, B: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitTaskStatus(t, queue, id, TASK_STATUS_OK)
+	result, _, err := QueryTyped[testTypedResult](queue, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sum != 4 {
+		t.Fatalf("结果为 %v", result)
+	}
+
+	id, err = PushTyped(queue, "count", []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitTaskStatus(t, queue, id, TASK_STATUS_OK)
+	count, _, err := QueryTyped[int](queue, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *count != 2 {
+		t.Fatalf("结果为 %d", *count)
+	}
+}
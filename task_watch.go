@@ -0,0 +1,83 @@
+package cjungo
+
+import "time"
+
+// 订阅任务结果的变化，返回取消订阅函数。
+// 订阅者处理不及时时丢弃最旧的通知。
+func (queue *TaskQueue) Watch(id string) (<-chan *TaskResult, func()) {
+	ch := make(chan *TaskResult, 16)
+	queue.watcherMutex.Lock()
+	queue.watchers[id] = append(queue.watchers[id], ch)
+	queue.watcherMutex.Unlock()
+
+	return ch, func() {
+		queue.watcherMutex.Lock()
+		defer queue.watcherMutex.Unlock()
+		watchers := queue.watchers[id]
+		for i, w := range watchers {
+			if w == ch {
+				watchers = append(watchers[:i], watchers[i+1:]...)
+				break
+			}
+		}
+		if len(watchers) == 0 {
+			delete(queue.watchers, id)
+		} else {
+			queue.watchers[id] = watchers
+		}
+	}
+}
+
+func (queue *TaskQueue) publish(result *TaskResult) {
+	queue.watcherMutex.Lock()
+	defer queue.watcherMutex.Unlock()
+	for _, ch := range queue.watchers[result.ID] {
+		r := *result
+		select {
+		case ch <- &r:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- &r:
+			default:
+			}
+		}
+	}
+}
+
+// 定期清理过期的任务结果。
+func (queue *TaskQueue) armPurge() {
+	if queue.resultTTL <= 0 {
+		return
+	}
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	select {
+	case <-queue.quit:
+		return
+	default:
+	}
+	queue.purgeTimer = queue.clock.AfterFunc(Min(queue.resultTTL, 10*time.Minute), func() {
+		queue.PurgeResults()
+		queue.armPurge()
+	})
+}
+
+// 删除超过 ResultTTL 的已结束任务。
+func (queue *TaskQueue) PurgeResults() {
+	if queue.resultTTL <= 0 {
+		return
+	}
+	before := queue.clock.Now().Add(-queue.resultTTL)
+	count, err := queue.store.Purge(before)
+	if err != nil {
+		queue.Logger.Error().Str("action", "清理任务结果出错").Err(err).Msg("[TASK]")
+		return
+	}
+	if count > 0 {
+		queue.Logger.Info().Str("action", "清理任务结果").Int("count", count).Time("before", before).Msg("[TASK]")
+	}
+}
@@ -0,0 +1,202 @@
+package cjungo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/dig"
+)
+
+type TracingConf struct {
+	ServiceName  *string           `env:"CJUNGO_TRACING_SERVICE_NAME" default:"cjungo"`
+	Exporter     *string           `env:"CJUNGO_TRACING_EXPORTER" default:"none"`                                 // none 不导出，otlp 通过 OTLP/HTTP 导出
+	OtlpEndpoint *string           `env:"CJUNGO_TRACING_OTLP_ENDPOINT" default:"http://127.0.0.1:4318/v1/traces"` // OTLP/HTTP 地址，使用 protobuf 编码
+	OtlpHeaders  map[string]string `env:"CJUNGO_TRACING_OTLP_HEADERS" secret:"true"`                              // 如 Authorization=Bearer xxx
+	SampleRatio  *float64          `env:"CJUNGO_TRACING_SAMPLE_RATIO" default:"1" min:"0" max:"1"`                // 没有上游采样决定时的采样率
+}
+
+const TRACING_NAME = "github.com/cjungo/cjungo"
+
+// 追踪上下文使用 W3C traceparent 、tracestate 和 baggage 。
+var tracingPropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// 链路追踪，方法允许 nil 接收者，没有启用时不产生 span ，但追踪上下文照常传递。
+type Tracing struct {
+	Provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+	logger   *zerolog.Logger
+}
+
+type TracingDi struct {
+	dig.In
+	Conf     *TracingConf          `optional:"true"`
+	Exporter sdktrace.SpanExporter `optional:"true"` // 提供时优先使用，测试可以用 tracetest.NewInMemoryExporter
+	Logger   *zerolog.Logger
+}
+
+// 没有配置导出时返回 nil 。
+func NewTracing(di TracingDi) (*Tracing, error) {
+	if di.Conf == nil {
+		di.Conf = NewDefaultConf[TracingConf]()
+	}
+	options := []sdktrace.TracerProviderOption{}
+	if di.Exporter != nil {
+		options = append(options, sdktrace.WithSyncer(di.Exporter))
+	} else {
+		switch name := GetOrDefault(di.Conf.Exporter, "none"); name {
+		case "none":
+			di.Logger.Info().Str("action", "没有启用链路追踪").Msg("[TRACE]")
+			return nil, nil
+		case "otlp":
+			exporter, err := newOtlpExporter(di.Conf)
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, sdktrace.WithBatcher(exporter))
+			di.Logger.Info().Str("action", "启用 OTLP 导出").Str("endpoint", GetOrDefault(di.Conf.OtlpEndpoint, "http://127.0.0.1:4318/v1/traces")).Msg("[TRACE]")
+		default:
+			return nil, fmt.Errorf("不支持的链路追踪导出 %s", name)
+		}
+	}
+
+	serviceName := GetOrDefault(di.Conf.ServiceName, "cjungo")
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	ratio := GetOrDefault(di.Conf.SampleRatio, 1)
+	options = append(options,
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	provider := sdktrace.NewTracerProvider(options...)
+
+	// 同时设为全局，方便其他库使用。
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(tracingPropagator)
+
+	di.Logger.Info().Str("action", "启用链路追踪").Str("service", serviceName).Float64("ratio", ratio).Msg("[TRACE]")
+	return &Tracing{
+		Provider: provider,
+		tracer:   provider.Tracer(TRACING_NAME),
+		logger:   di.Logger,
+	}, nil
+}
+
+func LoadTracingConfFromEnv(logger *zerolog.Logger) (*TracingConf, error) {
+	logger.Info().Str("action", "通过环境变量配置链路追踪").Msg("[TRACE]")
+	conf := &TracingConf{}
+	if err := LoadConf(conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func (tracing *Tracing) Name() string {
+	return "Tracing"
+}
+
+func (tracing *Tracing) Start(ctx context.Context) error {
+	return nil
+}
+
+// 导出剩余的 span 。
+func (tracing *Tracing) Stop(ctx context.Context) error {
+	return tracing.Provider.Shutdown(ctx)
+}
+
+// 开始一个 span ，没有启用时返回不记录的 span 。
+func (tracing *Tracing) StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if tracing == nil {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	return tracing.tracer.Start(ctx, name, opts...)
+}
+
+// 把追踪上下文写入 map ，用于任务、消息等。
+func InjectTraceContext(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	tracingPropagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// 从 map 读取追踪上下文。
+func ExtractTraceContext(ctx context.Context, carrier map[string]string) context.Context {
+	return tracingPropagator.Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// 把追踪上下文写入请求头，用于调用其他服务。
+func InjectTraceHeader(ctx context.Context, header http.Header) {
+	tracingPropagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// 当前的 trace ID ，没有时返回空字符串。
+func GetTraceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// 从 traceparent 读取上游追踪上下文，每个请求一个 server span ，并在响应头返回 traceparent 。
+func (tracing *Tracing) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := tracingPropagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			route := c.Path()
+			if len(route) == 0 {
+				route = req.URL.Path
+			}
+			ctx, span := tracing.StartSpan(ctx, req.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", req.Method),
+					attribute.String("http.route", route),
+					attribute.String("url.path", req.URL.Path),
+					attribute.String("client.address", c.RealIP()),
+					attribute.String("user_agent.original", req.UserAgent()),
+				),
+			)
+			defer span.End()
+			if reqId := getReqID(c); len(reqId) > 0 {
+				span.SetAttributes(attribute.String("http.request.id", reqId))
+			}
+			c.SetRequest(req.WithContext(ctx))
+			tracingPropagator.Inject(ctx, propagation.HeaderCarrier(c.Response().Header()))
+
+			// 错误已由内层交给 HTTPErrorHandler ，状态码为最终状态码。
+			err := next(c)
+			if err != nil {
+				span.RecordError(err)
+			}
+			status := c.Response().Status
+			span.SetAttributes(attribute.Int("http.response.status_code", status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, strconv.Itoa(status))
+			}
+			return err
+		}
+	}
+}
+
+func getReqID(c echo.Context) string {
+	if ctx, ok := c.(HttpContext); ok {
+		return ctx.GetReqID()
+	}
+	return ""
+}
@@ -0,0 +1,20 @@
+package cjungo
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// 通过 OTLP/HTTP 导出 span（protobuf 编码），地址为 http:// 时不使用 TLS 。
+// 没有配置的选项沿用 OTEL_EXPORTER_OTLP_* 环境变量。
+func newOtlpExporter(conf *TracingConf) (sdktrace.SpanExporter, error) {
+	options := []otlptracehttp.Option{
+		otlptracehttp.WithEndpointURL(GetOrDefault(conf.OtlpEndpoint, "http://127.0.0.1:4318/v1/traces")),
+	}
+	if len(conf.OtlpHeaders) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(conf.OtlpHeaders))
+	}
+	return otlptracehttp.New(context.Background(), options...)
+}
@@ -0,0 +1,220 @@
+package cjungo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestTracing(t *testing.T) (*Tracing, *tracetest.InMemoryExporter) {
+	t.Helper()
+	logger := zerolog.Nop()
+	exporter := tracetest.NewInMemoryExporter()
+	tracing, err := NewTracing(TracingDi{Exporter: exporter, Logger: &logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tracing.Stop(context.Background()) })
+	return tracing, exporter
+}
+
+func findTestSpan(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("没有 span %s", name)
+	return tracetest.SpanStub{}
+}
+
+func TestNewTracingExporter(t *testing.T) {
+	logger := zerolog.Nop()
+	if tracing, err := NewTracing(TracingDi{Logger: &logger}); tracing != nil || err != nil {
+		t.Fatalf("没有配置时为 %v %v", tracing, err)
+	}
+	name := "zipkin"
+	if _, err := NewTracing(TracingDi{Conf: &TracingConf{Exporter: &name}, Logger: &logger}); err == nil {
+		t.Fatal("不支持的导出应失败")
+	}
+
+	// otlp 使用 otlptracehttp ，创建时不连接。
+	name = "otlp"
+	endpoint := "https://collector.example.com:4318/v1/traces"
+	tracing, err := NewTracing(TracingDi{
+		Conf:   &TracingConf{Exporter: &name, OtlpEndpoint: &endpoint, OtlpHeaders: map[string]string{"Authorization": "Bearer x"}},
+		Logger: &logger,
+	})
+	if err != nil || tracing == nil {
+		t.Fatalf("otlp 为 %v %v", tracing, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tracing.Stop(ctx)
+}
+
+func TestTracingHttpSpan(t *testing.T) {
+	tracing, exporter := newTestTracing(t)
+	logger := zerolog.Nop()
+	router := NewRouter(NewRouterDi{Logger: &logger, Tracing: tracing})
+	traceId := ""
+	router.GET("/users/:id", func(ctx HttpContext) error {
+		traceId = GetTraceID(ctx.Request().Context())
+		return ctx.NoContent(http.StatusNoContent)
+	})
+
+	// 读取上游的 traceparent 。
+	upstream := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("traceparent", upstream)
+	rec := httptest.NewRecorder()
+	router.GetHandler().ServeHTTP(rec, req)
+	if traceId != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("trace ID 为 %s", traceId)
+	}
+
+	span := findTestSpan(t, exporter, "GET /users/:id")
+	if span.SpanKind != trace.SpanKindServer || span.Parent.SpanID().String() != "00f067aa0ba902b7" || !span.Parent.IsRemote() {
+		t.Fatalf("span 为 %+v", span)
+	}
+	if !hasTestAttribute(span.Attributes, attribute.Int("http.response.status_code", http.StatusNoContent)) {
+		t.Errorf("属性为 %v", span.Attributes)
+	}
+
+	// 响应头返回本次请求的 span 。
+	traceparent := rec.Header().Get("traceparent")
+	if traceparent != "00-4bf92f3577b34da6a3ce929d0e0e4736-"+span.SpanContext.SpanID().String()+"-01" {
+		t.Fatalf("响应头为 %s", traceparent)
+	}
+
+	// 调用其他服务时写入请求头。
+	header := http.Header{}
+	InjectTraceHeader(trace.ContextWithSpanContext(context.Background(), span.SpanContext), header)
+	if header.Get("traceparent") != traceparent {
+		t.Fatalf("请求头为 %s", header.Get("traceparent"))
+	}
+
+	// 没有上游时生成新的 trace 。
+	exporter.Reset()
+	rec = httptest.NewRecorder()
+	router.GetHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/2", nil))
+	if span := findTestSpan(t, exporter, "GET /users/:id"); span.Parent.IsValid() || !strings.Contains(rec.Header().Get("traceparent"), span.SpanContext.TraceID().String()) {
+		t.Fatalf("span 为 %+v", span)
+	}
+}
+
+func TestTracingHttpSpanError(t *testing.T) {
+	tracing, exporter := newTestTracing(t)
+	output := &bytes.Buffer{}
+	logger := zerolog.New(output)
+	router := NewRouter(NewRouterDi{Logger: &logger, Tracing: tracing})
+	router.GET("/fail", func(ctx HttpContext) error {
+		return ErrInternal(errors.New("出错"))
+	})
+	rec := httptest.NewRecorder()
+	router.GetHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	// 错误只处理一次，span 记录最终状态码和错误。
+	result := &ApiError{}
+	if err := json.Unmarshal(rec.Body.Bytes(), result); err != nil || rec.Code != http.StatusInternalServerError {
+		t.Fatalf("响应为 %d %s %v", rec.Code, rec.Body, err)
+	}
+	span := findTestSpan(t, exporter, "GET /fail")
+	if span.Status.Code != codes.Error || len(span.Events) != 1 || span.Events[0].Name != "exception" {
+		t.Fatalf("span 为 %+v", span)
+	}
+	if !hasTestAttribute(span.Attributes, attribute.Int("http.response.status_code", http.StatusInternalServerError)) {
+		t.Errorf("属性为 %v", span.Attributes)
+	}
+	// 错误继续返回给访问日志。
+	if !strings.Contains(output.String(), `"error":"{\"code\":50000`) {
+		t.Errorf("访问日志为 %s", output)
+	}
+}
+
+func TestTracingTaskLink(t *testing.T) {
+	tracing, exporter := newTestTracing(t)
+	queue := newTestTaskQueue(t, TaskQueueDi{Tracing: tracing})
+	queue.RegisterProcess("echo", func(action *TaskAction) (TaskResultMessage, error) {
+		return nil, nil
+	})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, request := tracing.StartSpan(context.Background(), "request")
+	id, err := queue.PushTaskContext(ctx, "echo", TaskActionParam{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.End()
+	waitTaskStatus(t, queue, id, TASK_STATUS_OK)
+	stopTestTaskQueue(t, queue) // 等待任务的 span 结束
+
+	push := findTestSpan(t, exporter, "push echo")
+	if push.Parent.SpanID() != request.SpanContext().SpanID() {
+		t.Fatalf("push 的父级为 %s", push.Parent.SpanID())
+	}
+	// 任务是新的 trace ，链接到推送任务的 span 。
+	task := findTestSpan(t, exporter, "task echo")
+	if task.Parent.IsValid() || task.SpanContext.TraceID() == request.SpanContext().TraceID() {
+		t.Fatalf("任务 span 为 %+v", task)
+	}
+	if len(task.Links) != 1 || task.Links[0].SpanContext.SpanID() != push.SpanContext.SpanID() {
+		t.Fatalf("链接为 %+v", task.Links)
+	}
+}
+
+func hasTestAttribute(attributes []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, item := range attributes {
+		if item == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTracingDelayedTaskLink(t *testing.T) {
+	tracing, exporter := newTestTracing(t)
+	clock := NewManualClock(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	queue := newTestTaskQueue(t, TaskQueueDi{Tracing: tracing, Clock: clock})
+	queue.RegisterProcess("echo", func(action *TaskAction) (TaskResultMessage, error) {
+		return nil, nil
+	})
+	if err := queue.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 延迟任务同样记录推送时的追踪上下文。
+	ctx, request := tracing.StartSpan(context.Background(), "request")
+	id, err := queue.PushTaskAfterContext(ctx, "echo", TaskActionParam{}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.End()
+	clock.Advance(time.Minute)
+	waitTaskStatus(t, queue, id, TASK_STATUS_OK)
+	stopTestTaskQueue(t, queue)
+
+	push := findTestSpan(t, exporter, "push echo")
+	if push.Parent.SpanID() != request.SpanContext().SpanID() || !hasTestAttribute(push.Attributes, attribute.String("task.id", id)) {
+		t.Fatalf("push span 为 %+v", push)
+	}
+	task := findTestSpan(t, exporter, "task echo")
+	if len(task.Links) != 1 || task.Links[0].SpanContext.SpanID() != push.SpanContext.SpanID() {
+		t.Fatalf("链接为 %+v", task.Links)
+	}
+}